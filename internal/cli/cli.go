@@ -0,0 +1,42 @@
+// Package cli holds helpers shared by the commands of this module.
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Interruptible returns a context which is cancelled on SIGINT or
+// SIGTERM, so that a command can stop cleanly. A second signal kills
+// the program as usual.
+func Interruptible(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// SplitList splits a comma separated flag value, ignoring blanks
+func SplitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
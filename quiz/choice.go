@@ -0,0 +1,115 @@
+package quiz
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types of problems
+const (
+	TypeText      = "text"
+	TypeChoice    = "choice"
+	TypeTrueFalse = "truefalse"
+)
+
+var trueFalseChoices = []string{"True", "False"}
+
+// normalizeChoices fills in the type and choices of a problem and
+// rewrites its answer to the text of the correct choice, so that it
+// can be given as a letter, a 1-based index or the choice itself
+func normalizeChoices(p *Problem) error {
+	switch strings.ToLower(p.Type) {
+	case "", TypeText:
+		if len(p.Choices) == 0 {
+			p.Type = TypeText
+			return nil
+		}
+		p.Type = TypeChoice
+	case TypeChoice, "multiple", "mc":
+		p.Type = TypeChoice
+		if len(p.Choices) < 2 {
+			return fmt.Errorf("a choice question needs at least two choices")
+		}
+	case TypeTrueFalse, "tf", "bool", "boolean":
+		p.Type = TypeTrueFalse
+		p.Choices = trueFalseChoices
+	default:
+		return fmt.Errorf("unknown question type %q", p.Type)
+	}
+
+	readings := p.readings(p.Answer)
+	switch {
+	case len(readings) == 0:
+		return fmt.Errorf("answer %q is not one of the choices", p.Answer)
+	case len(readings) > 1:
+		return fmt.Errorf("answer %q could mean %q or %q, give the letter of the choice", p.Answer, readings[0], readings[1])
+	}
+	p.Answer = readings[0]
+	return nil
+}
+
+// choice resolves the given input to the text of one of the choices
+// of the problem. It accepts the text of the choice in any case, a
+// letter or a 1-based index, and true/false style words for true or
+// false problems. The text of a choice wins over a letter or index
+// meaning another choice, so that choices like numbers can be typed.
+func (p Problem) choice(given string) (string, bool) {
+	readings := p.readings(given)
+	if len(readings) == 0 {
+		return "", false
+	}
+	return readings[0], true
+}
+
+// readings returns all the choices given could stand for, by order
+// of precedence and without duplicates
+func (p Problem) readings(given string) []string {
+	given = strings.TrimSpace(given)
+	if given == "" {
+		return nil
+	}
+
+	var readings []string
+	add := func(c string) {
+		for _, r := range readings {
+			if r == c {
+				return
+			}
+		}
+		readings = append(readings, c)
+	}
+
+	if p.Type == TypeTrueFalse {
+		switch strings.ToLower(given) {
+		case "t", "true", "y", "yes":
+			add(trueFalseChoices[0])
+		case "f", "false", "n", "no":
+			add(trueFalseChoices[1])
+		}
+	}
+
+	for _, c := range p.Choices {
+		if strings.EqualFold(normalizeSpace(c), normalizeSpace(given)) {
+			add(c)
+		}
+	}
+
+	if len(given) == 1 {
+		i := int(strings.ToLower(given)[0]) - 'a'
+		if i >= 0 && i < len(p.Choices) {
+			add(p.Choices[i])
+		}
+	}
+
+	if i, err := strconv.Atoi(given); err == nil && i >= 1 && i <= len(p.Choices) {
+		add(p.Choices[i-1])
+	}
+
+	return readings
+}
+
+// letter returns the letter used to label the i-th choice
+func letter(i int) string {
+	return string(rune('a' + i))
+}
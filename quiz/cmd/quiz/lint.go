@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/prmsrswt/gophercises/quiz"
+)
+
+// lint checks question files and exits with a non-zero status if
+// any errors are found, see 'quiz lint -h'
+func lint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := fs.String("format", "", "format of the question files (default: detected)")
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: quiz lint [flags] files...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"problems.csv"}
+	}
+
+	failed := false
+	for _, path := range paths {
+		diags, err := quiz.LintFile(path, *format)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+
+		for _, d := range diags {
+			fmt.Println(d)
+			if !d.Warning || *strict {
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
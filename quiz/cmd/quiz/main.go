@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prmsrswt/gophercises/internal/cli"
+	"github.com/prmsrswt/gophercises/quiz"
+)
+
+var (
+	csvPath      string
+	format       string
+	limit        int
+	qlimit       int
+	shuffle      bool
+	details      bool
+	reportPath   string
+	reportFormat string
+	match        string
+	gen          quiz.GeneratorConfig
+	genOut       string
+	practice     bool
+	statePath    string
+	pause        int
+	scoring      quiz.Scoring
+	categories   string
+	difficulties string
+	adaptive     bool
+)
+
+func init() {
+	flag.StringVar(&csvPath, "csv", "problems.csv", `a question file, either csv in the format of 'questions,answers' or json, yaml or toml`)
+	flag.StringVar(&format, "format", "", "format of the question file: csv, json, yaml or toml (default: detected)")
+	flag.IntVar(&limit, "limit", 30, "time limit for the quiz in seconds")
+	flag.IntVar(&qlimit, "qlimit", 0, "time limit for each question in seconds, 0 for none")
+	flag.StringVar(&match, "match", "exact", "how answers are matched: "+strings.Join(quiz.MatcherNames(), ", "))
+	flag.IntVar(&pause, "pause", 0, "allow pausing the quiz by answering "+quiz.PauseCommand+" for up to this many seconds in total")
+	flag.BoolVar(&shuffle, "shuffle", false, "shuffle questions before asking")
+	flag.BoolVar(&details, "details", false, "print a detailed report after the quiz")
+	flag.StringVar(&reportPath, "report", "", "write a detailed report of the quiz to this file")
+	flag.StringVar(&reportFormat, "report-format", "", "format of the report file, json or csv (default: from the file extension)")
+
+	flag.IntVar(&gen.Count, "gen", 0, "generate this many arithmetic problems instead of reading a question file")
+	flag.StringVar(&gen.Ops, "ops", "+-", "operators used by generated problems, any of +-*/")
+	flag.IntVar(&gen.Min, "min", 0, "smallest operand of generated problems")
+	flag.IntVar(&gen.Max, "max", 10, "largest operand of generated problems")
+	flag.Int64Var(&gen.Seed, "seed", 0, "seed for generated problems, 0 for a random one")
+	flag.StringVar(&genOut, "gen-out", "", "write the generated problems as csv to this file and exit")
+
+	flag.Float64Var(&scoring.Penalty, "penalty", 0, "points subtracted for a wrong answer (default: from the question file)")
+	flag.Float64Var(&scoring.TimeBonus, "bonus", 0, "fraction of the points awarded on top for quick correct answers (default: from the question file)")
+	flag.Float64Var(&scoring.BonusTime, "bonus-time", 0, "answers within this many seconds get the time bonus (default: from the question file)")
+	flag.Float64Var(&scoring.PassMark, "pass", 0, "percentage of the maximum score needed to pass (default: from the question file)")
+
+	flag.StringVar(&categories, "category", "", "only ask questions in these comma separated categories")
+	flag.StringVar(&difficulties, "difficulty", "", "only ask questions of these comma separated difficulties")
+	flag.BoolVar(&adaptive, "adaptive", false, "pick questions by difficulty, getting harder or easier based on your answers")
+
+	flag.BoolVar(&practice, "practice", false, "practice mode, asks the questions due for spaced repetition review first")
+	flag.StringVar(&statePath, "state", "", "spaced repetition state file (default: practice.json in the user config dir)")
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "lint":
+			lint(os.Args[2:])
+			return
+		case "hotseat":
+			hotseat(os.Args[2:])
+			return
+		case "host":
+			host(os.Args[2:])
+			return
+		case "join":
+			join(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
+
+	matcher, err := quiz.MatcherByName(match)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bank, err := loadBank()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	problems := quiz.Filter(bank.Problems, cli.SplitList(categories), cli.SplitList(difficulties))
+	if len(problems) == 0 {
+		fmt.Println("No questions match the given category and difficulty.")
+		os.Exit(1)
+	}
+
+	if genOut != "" {
+		if err := writeProblems(problems); err != nil {
+			fmt.Println("Error writing problems:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if shuffle {
+		rand.Seed(time.Now().UnixNano())
+		quiz.Shuffle(problems)
+	}
+
+	var deck *quiz.Deck
+	if practice {
+		deck, err = loadDeck()
+		if err != nil {
+			fmt.Println("Error loading practice state:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d of %d questions are due for review.\n", deck.Due(problems, time.Now()), len(problems))
+		problems = deck.Schedule(problems, time.Now())
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	fmt.Printf("Time limit for the quiz: %ds\nPress enter to start the quiz. ", limit)
+	in.ReadString('\n')
+
+	if pause > 0 {
+		fmt.Printf("Answer %s to pause the quiz.\n", quiz.PauseCommand)
+	}
+
+	ctx, cancel := cli.Interruptible(context.Background())
+	defer cancel()
+
+	opts := []quiz.Option{
+		quiz.WithTimeLimit(time.Duration(limit) * time.Second),
+		quiz.WithQuestionLimit(time.Duration(qlimit) * time.Second),
+		quiz.WithPause(time.Duration(pause) * time.Second),
+		quiz.WithMatcher(matcher),
+		quiz.WithScoring(bankScoring(bank.Scoring)),
+	}
+	if adaptive {
+		opts = append(opts, quiz.WithAdaptive())
+	}
+
+	q := quiz.New(problems, in, os.Stdout, opts...)
+	defer q.Close()
+	res, err := q.Run(ctx)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	switch res.End {
+	case quiz.EndTimeUp:
+		fmt.Print("\nTime's up!")
+	case quiz.EndInterrupted:
+		fmt.Print("\nQuiz stopped.")
+	}
+	fmt.Printf("\nYou scored %d out of %d, %d wrong and %d unanswered.\n",
+		res.Correct, res.Total, res.Wrong, res.Unanswered())
+	fmt.Printf("Score: %g out of %g (%.1f%%).\n", res.Score, res.MaxScore, res.Percent())
+	if cats := res.ByCategory(); len(cats) > 1 || cats[0].Category != "" {
+		fmt.Println()
+		for _, c := range cats {
+			name := c.Category
+			if name == "" {
+				name = "uncategorized"
+			}
+			fmt.Printf("  %s: %d out of %d, %d wrong, %g points\n", name, c.Correct, c.Total, c.Wrong, c.Points)
+		}
+	}
+
+	if res.PassMark > 0 {
+		if res.Passed {
+			fmt.Printf("Passed, the pass mark is %g%%.\n", res.PassMark)
+		} else {
+			fmt.Printf("Failed, the pass mark is %g%%.\n", res.PassMark)
+		}
+	}
+
+	if deck != nil {
+		now := time.Now()
+		for i, a := range res.Answers {
+			// Questions which timed out count as wrong, the ones never
+			// asked because the quiz ended aren't reviewed
+			if a.Answered || a.TimedOut {
+				deck.Review(problems[i], quiz.Quality(a), now)
+			}
+		}
+		if err := deck.Save(); err != nil {
+			fmt.Println("Error saving practice state:", err)
+		}
+	}
+
+	if details {
+		fmt.Println()
+		res.WriteText(os.Stdout)
+	}
+
+	if reportPath != "" {
+		if err := writeReport(res); err != nil {
+			fmt.Println("Error writing report:", err)
+			os.Exit(1)
+		}
+	}
+
+	if !res.Passed {
+		os.Exit(1)
+	}
+}
+
+// loadBank reads the question file, or generates problems when
+// asked to
+func loadBank() (quiz.Bank, error) {
+	if gen.Count <= 0 {
+		return quiz.LoadBankFile(csvPath, format)
+	}
+
+	if gen.Seed == 0 {
+		gen.Seed = time.Now().UnixNano()
+		fmt.Printf("Generating problems with seed %d\n", gen.Seed)
+	}
+	problems, err := quiz.Generate(gen)
+	return quiz.Bank{Problems: problems}, err
+}
+
+// bankScoring returns the scoring of the question file with the
+// scoring flags given on the command line taking precedence
+func bankScoring(s quiz.Scoring) quiz.Scoring {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "penalty":
+			s.Penalty = scoring.Penalty
+		case "bonus":
+			s.TimeBonus = scoring.TimeBonus
+		case "bonus-time":
+			s.BonusTime = scoring.BonusTime
+		case "pass":
+			s.PassMark = scoring.PassMark
+		}
+	})
+	return s
+}
+
+// loadDeck loads the spaced repetition state, which is kept in the
+// user config dir unless a path is given
+func loadDeck() (*quiz.Deck, error) {
+	if statePath == "" {
+		confDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		baseDir := filepath.Join(confDir, "quiz")
+		if err := os.MkdirAll(baseDir, 0755); err != nil {
+			return nil, err
+		}
+		statePath = filepath.Join(baseDir, "practice.json")
+	}
+
+	return quiz.LoadDeck(statePath)
+}
+
+func writeProblems(problems []quiz.Problem) error {
+	f, err := os.Create(genOut)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return quiz.WriteCSV(f, problems)
+}
+
+func writeReport(res quiz.Result) error {
+	format := reportFormat
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(reportPath), ".")
+	}
+
+	f, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case "json":
+		return res.WriteJSON(f)
+	case "csv":
+		return res.WriteCSV(f)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/prmsrswt/gophercises/internal/cli"
+	"github.com/prmsrswt/gophercises/quiz"
+)
+
+// roundFlags registers the flags shared by the multi-player modes
+// and returns a function building the round once they are parsed
+func roundFlags(fs *flag.FlagSet) func() (*quiz.Round, error) {
+	path := fs.String("csv", "problems.csv", "a question file in csv, json, yaml or toml")
+	format := fs.String("format", "", "format of the question file (default: detected)")
+	qlimit := fs.Int("qlimit", 15, "time to answer each question in seconds")
+	match := fs.String("match", "exact", "how answers are matched")
+
+	return func() (*quiz.Round, error) {
+		matcher, err := quiz.MatcherByName(*match)
+		if err != nil {
+			return nil, err
+		}
+
+		problems, err := quiz.LoadFile(*path, *format)
+		if err != nil {
+			return nil, err
+		}
+
+		return quiz.NewRound(problems, time.Duration(*qlimit)*time.Second,
+			quiz.WithRoundMatcher(matcher),
+			quiz.WithRoundLog(os.Stdout),
+		), nil
+	}
+}
+
+// hotseat plays a round with the players taking turns on this
+// terminal, see 'quiz hotseat -h'
+func hotseat(args []string) {
+	fs := flag.NewFlagSet("hotseat", flag.ExitOnError)
+	names := fs.String("players", "", "comma separated names of the players")
+	newRound := roundFlags(fs)
+	fs.Parse(args)
+
+	players := cli.SplitList(*names)
+	if len(players) < 2 {
+		fmt.Println("At least two players are needed, see -players.")
+		os.Exit(1)
+	}
+
+	round, err := newRound()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := cli.Interruptible(context.Background())
+	defer cancel()
+
+	standings := round.HotSeat(ctx, players, os.Stdin, os.Stdout)
+	fmt.Println("\nFinal standings:")
+	quiz.WriteStandings(os.Stdout, standings)
+}
+
+// host waits for players to connect over TCP and plays a round with
+// all of them at once, see 'quiz host -h'
+func host(args []string) {
+	fs := flag.NewFlagSet("host", flag.ExitOnError)
+	addr := fs.String("addr", ":4000", "the address to listen on")
+	n := fs.Int("players", 2, "number of players to wait for before starting")
+	newRound := roundFlags(fs)
+	fs.Parse(args)
+
+	round, err := newRound()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer l.Close()
+
+	ctx, cancel := cli.Interruptible(context.Background())
+	defer cancel()
+
+	fmt.Printf("Waiting for %d players on %s, join with 'quiz join %s' or netcat.\n", *n, l.Addr(), l.Addr())
+	standings, err := round.Serve(ctx, l, *n)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("\nFinal standings:")
+	quiz.WriteStandings(os.Stdout, standings)
+}
+
+// join connects this terminal to a round hosted with 'quiz host'
+func join(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: quiz join host:port")
+		os.Exit(1)
+	}
+
+	conn, err := net.Dial("tcp", args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	go io.Copy(conn, os.Stdin)
+	io.Copy(os.Stdout, conn)
+}
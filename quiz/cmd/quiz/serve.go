@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prmsrswt/gophercises/quiz"
+)
+
+// serve runs the quiz as an HTTP server, see 'quiz serve -h'
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "the address to listen on")
+	path := fs.String("csv", "problems.csv", "a question file in csv, json, yaml or toml")
+	format := fs.String("format", "", "format of the question file (default: detected)")
+	limit := fs.Int("limit", 30, "time limit for each player in seconds, 0 for none")
+	qlimit := fs.Int("qlimit", 0, "time limit for each question in seconds, 0 for none")
+	match := fs.String("match", "exact", "how answers are matched")
+	shuffle := fs.Bool("shuffle", false, "shuffle questions before serving them")
+	boardPath := fs.String("leaderboard", "", "persist the leaderboard to this JSON file")
+	fs.Parse(args)
+
+	matcher, err := quiz.MatcherByName(*match)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	problems, err := quiz.LoadFile(*path, *format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s has no questions\n", *path)
+		os.Exit(1)
+	}
+
+	if *shuffle {
+		rand.Seed(time.Now().UnixNano())
+		quiz.Shuffle(problems)
+	}
+
+	board, err := quiz.NewLeaderboard(*boardPath)
+	if err != nil {
+		fmt.Println("Error loading leaderboard:", err)
+		os.Exit(1)
+	}
+
+	s := quiz.NewServer(problems, time.Duration(*limit)*time.Second,
+		quiz.WithLeaderboard(board),
+		quiz.WithServerMatcher(matcher),
+		quiz.WithServerQuestionLimit(time.Duration(*qlimit)*time.Second),
+	)
+
+	fmt.Printf("Server starting on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
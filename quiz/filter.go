@@ -0,0 +1,162 @@
+package quiz
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter returns the problems in any of the given categories and
+// of any of the given difficulties, compared case insensitively.
+// An empty list doesn't filter on that field.
+func Filter(problems []Problem, categories, difficulties []string) []Problem {
+	var filtered []Problem
+	for _, p := range problems {
+		if len(categories) > 0 && !containsFold(categories, p.Category) {
+			continue
+		}
+		if len(difficulties) > 0 && !containsFold(difficulties, p.Difficulty) &&
+			!containsLevel(difficulties, DifficultyLevel(p.Difficulty)) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLevel(list []string, level int) bool {
+	for _, v := range list {
+		if l := DifficultyLevel(v); l > 0 && l == level {
+			return true
+		}
+	}
+	return false
+}
+
+var difficultyLevels = map[string]int{
+	"easy":   1,
+	"medium": 2,
+	"normal": 2,
+	"hard":   3,
+	"expert": 4,
+}
+
+// DifficultyLevel turns a difficulty like "easy" or "3" into a
+// level where higher is harder. Unknown difficulties are level 0.
+func DifficultyLevel(difficulty string) int {
+	d := strings.ToLower(strings.TrimSpace(difficulty))
+	if l, ok := difficultyLevels[d]; ok {
+		return l
+	}
+	if l, err := strconv.Atoi(d); err == nil && l > 0 {
+		return l
+	}
+	return 0
+}
+
+// WithAdaptive is an option to pick problems by difficulty instead
+// of asking them in order. The quiz starts with the easiest problems,
+// moves a level up after two correct answers in a row and a level
+// down after every wrong or missed answer.
+func WithAdaptive() Option {
+	return func(q *Quiz) {
+		q.adaptive = true
+	}
+}
+
+// adaptiveNext returns the index of the unasked problem closest to
+// the current difficulty level of the quiz, -1 if none is left
+func (q *Quiz) adaptiveNext(asked []bool) int {
+	if q.levels == nil {
+		seen := make(map[int]bool)
+		for _, p := range q.problems {
+			if l := DifficultyLevel(p.Difficulty); !seen[l] {
+				seen[l] = true
+				q.levels = append(q.levels, l)
+			}
+		}
+		sort.Ints(q.levels)
+	}
+	if len(q.levels) == 0 {
+		return -1
+	}
+	target := q.levels[q.level]
+
+	next, best := -1, 0
+	for i, p := range q.problems {
+		if asked[i] {
+			continue
+		}
+		dist := DifficultyLevel(p.Difficulty) - target
+		if dist < 0 {
+			dist = -dist
+		}
+		if next < 0 || dist < best {
+			next, best = i, dist
+		}
+	}
+	return next
+}
+
+// adapt moves the difficulty level after an answer
+func (q *Quiz) adapt(correct bool) {
+	if !correct {
+		q.streak = 0
+		if q.level > 0 {
+			q.level--
+		}
+		return
+	}
+
+	q.streak++
+	if q.streak >= 2 && q.level < len(q.levels)-1 {
+		q.streak = 0
+		q.level++
+	}
+}
+
+// CategoryScore is the part of a result for a single category
+type CategoryScore struct {
+	Category string  `json:"category"`
+	Correct  int     `json:"correct"`
+	Wrong    int     `json:"wrong"`
+	Total    int     `json:"total"`
+	Points   float64 `json:"points"`
+}
+
+// ByCategory breaks the result down per category, in the order the
+// categories first appear. Problems without one are grouped under
+// an empty category.
+func (r Result) ByCategory() []CategoryScore {
+	var scores []CategoryScore
+	index := make(map[string]int)
+
+	for _, a := range r.Answers {
+		i, ok := index[a.Category]
+		if !ok {
+			i = len(scores)
+			index[a.Category] = i
+			scores = append(scores, CategoryScore{Category: a.Category})
+		}
+
+		cs := &scores[i]
+		cs.Total++
+		cs.Points += a.Points
+		switch {
+		case a.Correct:
+			cs.Correct++
+		case a.Answered:
+			cs.Wrong++
+		}
+	}
+	return scores
+}
@@ -0,0 +1,103 @@
+package quiz
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// GeneratorConfig configures the arithmetic problems made by Generate
+type GeneratorConfig struct {
+	// Ops holds the operators to use, any of "+-*/"
+	Ops string
+	// Min and Max bound the operands, both inclusive
+	Min, Max int
+	// Count is the number of problems to generate
+	Count int
+	// Seed makes the generated set reproducible
+	Seed int64
+}
+
+// Generate synthesizes arithmetic problems like "5+5" with integer
+// answers. Divisions are always exact and never by zero.
+func Generate(c GeneratorConfig) ([]Problem, error) {
+	if c.Ops == "" {
+		return nil, fmt.Errorf("no operators given")
+	}
+	if i := strings.IndexFunc(c.Ops, func(r rune) bool { return !strings.ContainsRune("+-*/", r) }); i >= 0 {
+		return nil, fmt.Errorf("unknown operator %q", c.Ops[i])
+	}
+	if c.Min > c.Max {
+		return nil, fmt.Errorf("min %d is greater than max %d", c.Min, c.Max)
+	}
+	if strings.Contains(c.Ops, "/") && c.Min == 0 && c.Max == 0 {
+		return nil, fmt.Errorf("cannot generate divisions with only zero operands")
+	}
+
+	rnd := rand.New(rand.NewSource(c.Seed))
+	operand := func() int {
+		return c.Min + rnd.Intn(c.Max-c.Min+1)
+	}
+
+	problems := make([]Problem, 0, c.Count)
+	for len(problems) < c.Count {
+		op := c.Ops[rnd.Intn(len(c.Ops))]
+		a, b := operand(), operand()
+
+		var ans int
+		switch op {
+		case '+':
+			ans = a + b
+		case '-':
+			ans = a - b
+		case '*':
+			ans = a * b
+		case '/':
+			if b == 0 {
+				continue
+			}
+			// Build the dividend from the quotient so the division is exact
+			ans = a
+			a = a * b
+		}
+
+		problems = append(problems, Problem{
+			Question: fmt.Sprintf("%d%c%s", a, op, operandString(b)),
+			Answer:   strconv.Itoa(ans),
+			Type:     TypeText,
+			Match:    "numeric",
+		})
+	}
+
+	return problems, nil
+}
+
+// operandString formats the right operand of a generated problem,
+// with parentheses when it is negative so that problems read like
+// 5-(-3) rather than 5--3
+func operandString(n int) string {
+	if n < 0 {
+		return "(" + strconv.Itoa(n) + ")"
+	}
+	return strconv.Itoa(n)
+}
+
+// WriteCSV writes the problems in the 'questions,answers' format
+// read by ParseProblems, with the matcher as a third column when
+// a problem names one. Choices can't be written in this format.
+func WriteCSV(w io.Writer, problems []Problem) error {
+	cw := csv.NewWriter(w)
+	for _, p := range problems {
+		row := []string{p.Question, p.Answer}
+		if p.Match != "" {
+			row = append(row, p.Match)
+		}
+		cw.Write(row)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
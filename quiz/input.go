@@ -0,0 +1,197 @@
+package quiz
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lineReader reads input line by line in the background so that
+// waiting for a line can be abandoned when a context is done. A line
+// is only read once asked for, so the input isn't read ahead of what
+// the quiz needs, unless the reader is eager.
+type lineReader struct {
+	r     io.Reader
+	want  chan struct{}
+	lines chan line
+	done  chan struct{}
+	start sync.Once
+	stop  sync.Once
+
+	// pending is set while a line was asked for but not received yet
+	pending bool
+	eof     bool
+	// eager readers read every line as soon as it arrives
+	eager bool
+}
+
+// eagerLines is how many lines an eager lineReader keeps until they
+// are asked for, older lines are dropped once there are more
+const eagerLines = 16
+
+type line struct {
+	text string
+	at   time.Time
+	eof  bool
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{
+		r:     r,
+		want:  make(chan struct{}),
+		lines: make(chan line),
+		done:  make(chan struct{}),
+	}
+}
+
+// newEagerLineReader returns a lineReader which reads lines as soon
+// as they arrive rather than once asked for, so that the time of every
+// line is when it came in. It is meant for connections of players,
+// whose input isn't shared. The reading stops once r is closed.
+func newEagerLineReader(r io.Reader) *lineReader {
+	l := &lineReader{
+		r:     r,
+		lines: make(chan line, eagerLines),
+		done:  make(chan struct{}),
+		eager: true,
+	}
+	l.start.Do(func() { go l.scanEager() })
+	return l
+}
+
+// readLine returns the next line of input without the surrounding
+// whitespace. It returns ctx.Err() if the context is done first.
+func (l *lineReader) readLine(ctx context.Context) (string, error) {
+	text, _, err := l.readLineSince(ctx, time.Time{})
+	return text, err
+}
+
+// readLineSince is like readLine but skips lines which were read
+// before since, such as answers which came in too late for the last
+// question. It also returns the time the line was read at.
+func (l *lineReader) readLineSince(ctx context.Context, since time.Time) (string, time.Time, error) {
+	l.start.Do(func() { go l.scan() })
+
+	for {
+		if l.eof {
+			return "", time.Time{}, io.EOF
+		}
+		if !l.eager && !l.pending {
+			select {
+			case l.want <- struct{}{}:
+				l.pending = true
+			case <-l.done:
+				return "", time.Time{}, io.EOF
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", time.Time{}, ctx.Err()
+		case <-l.done:
+			return "", time.Time{}, io.EOF
+		case ln := <-l.lines:
+			l.pending = false
+			if ln.eof {
+				l.eof = true
+				continue
+			}
+			if ln.at.Before(since) {
+				continue
+			}
+			return strings.TrimSpace(ln.text), ln.at, nil
+		}
+	}
+}
+
+// scan reads a line from the input every time one is asked for,
+// until the input ends or the reader is closed. Any error reading
+// the input counts as its end.
+func (l *lineReader) scan() {
+	var err error
+	for {
+		select {
+		case <-l.want:
+		case <-l.done:
+			return
+		}
+
+		ln := line{eof: err != nil}
+		if err == nil {
+			ln.text, err = readString(l.r)
+			ln.at = time.Now()
+			ln.eof = ln.text == "" && err != nil
+		}
+
+		select {
+		case l.lines <- ln:
+		case <-l.done:
+			return
+		}
+		if ln.eof {
+			return
+		}
+	}
+}
+
+// scanEager reads lines until the input ends or the reader is closed,
+// queueing them along with the time they arrived at
+func (l *lineReader) scanEager() {
+	for {
+		text, err := readString(l.r)
+		at := time.Now()
+		if text != "" && !l.queue(line{text: text, at: at}) {
+			return
+		}
+		if err != nil {
+			l.queue(line{at: at, eof: true})
+			return
+		}
+	}
+}
+
+// queue adds ln to the lines of an eager reader, dropping the oldest
+// line when it has too many. It reports false once the reader is
+// closed.
+func (l *lineReader) queue(ln line) bool {
+	for {
+		select {
+		case l.lines <- ln:
+			return true
+		case <-l.done:
+			return false
+		default:
+		}
+		select {
+		case <-l.lines:
+		default:
+		}
+	}
+}
+
+// close stops the background reading. A line which was asked for
+// but not received yet is still read from the input and dropped.
+func (l *lineReader) close() {
+	l.stop.Do(func() { close(l.done) })
+}
+
+// readString reads up to and including the next newline one byte at
+// a time, so that nothing past the line is taken from r
+func readString(r io.Reader) (string, error) {
+	var text []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			text = append(text, b[0])
+			if b[0] == '\n' {
+				return string(text), nil
+			}
+		}
+		if err != nil {
+			return string(text), err
+		}
+	}
+}
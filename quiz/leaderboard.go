@@ -0,0 +1,95 @@
+package quiz
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Score is a single entry of the leaderboard
+type Score struct {
+	Name     string        `json:"name"`
+	Correct  int           `json:"correct"`
+	Total    int           `json:"total"`
+	Duration time.Duration `json:"duration_ns"`
+	Date     time.Time     `json:"date"`
+}
+
+// Leaderboard keeps the scores of finished quizzes, optionally
+// persisting them as JSON to a local file
+type Leaderboard struct {
+	mu     sync.Mutex
+	path   string
+	scores []Score
+}
+
+// NewLeaderboard creates a leaderboard. If path is not empty the
+// scores already stored in it are loaded, and every new score is
+// written back to it.
+func NewLeaderboard(path string) (*Leaderboard, error) {
+	b := &Leaderboard{path: path}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &b.scores); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add records a new score and persists the leaderboard
+func (b *Leaderboard) Add(s Score) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.scores = append(b.scores, s)
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.scores, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves
+	// a half written leaderboard behind
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// Top returns the best n scores, most correct answers first and
+// quickest first among equals. If n <= 0 all scores are returned.
+func (b *Leaderboard) Top(n int) []Score {
+	b.mu.Lock()
+	scores := make([]Score, len(b.scores))
+	copy(scores, b.scores)
+	b.mu.Unlock()
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Correct != scores[j].Correct {
+			return scores[i].Correct > scores[j].Correct
+		}
+		return scores[i].Duration < scores[j].Duration
+	})
+
+	if n > 0 && len(scores) > n {
+		scores = scores[:n]
+	}
+	return scores
+}
@@ -0,0 +1,335 @@
+package quiz
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Diagnostic is a problem found in a question file. Line is zero
+// when the problem can't be tied to a single line.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Warning bool
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	kind := "error"
+	if d.Warning {
+		kind = "warning"
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, kind, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, kind, d.Message)
+}
+
+// LintFile checks the question file at path, see Lint
+func LintFile(path, format string) ([]Diagnostic, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = DetectFormat(path, data)
+	}
+	return Lint(path, data, format), nil
+}
+
+// Lint checks a question file for encoding issues, missing answers,
+// invalid choices and matchers and duplicate questions, reporting
+// every issue found along with the line of the problem it is in. CSV
+// files are checked for empty lines and missing or extra columns as
+// well.
+func Lint(name string, data []byte, format string) []Diagnostic {
+	l := &linter{name: name}
+	l.encoding(data)
+
+	if format == FormatCSV {
+		l.csv(data)
+	} else {
+		l.bank(data, format)
+	}
+	sort.SliceStable(l.diags, func(i, j int) bool { return l.diags[i].Line < l.diags[j].Line })
+	return l.diags
+}
+
+type linter struct {
+	name  string
+	diags []Diagnostic
+}
+
+func (l *linter) errorf(line int, format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{File: l.name, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(line int, format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{File: l.name, Line: line, Warning: true, Message: fmt.Sprintf(format, args...)})
+}
+
+// encoding reports invalid UTF-8, byte order marks and control
+// characters, line by line
+func (l *linter) encoding(data []byte) {
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		l.warnf(1, "file starts with a UTF-8 byte order mark")
+	}
+
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if !utf8.Valid(line) {
+			l.errorf(i+1, "invalid UTF-8")
+			continue
+		}
+		for _, r := range strings.TrimRight(string(line), "\r") {
+			if unicode.IsControl(r) && r != '\t' {
+				l.errorf(i+1, "control character %U", r)
+				break
+			}
+		}
+	}
+}
+
+// csv checks every record of a 'questions,answers' file along with
+// the line it starts on
+func (l *linter) csv(data []byte) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	seen := make(map[string]int)
+
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		record := strings.TrimRight(lines[i], "\r")
+
+		if strings.TrimSpace(record) == "" {
+			l.warnf(n, "empty line")
+			continue
+		}
+
+		// A quoted field may span several lines
+		for strings.Count(record, `"`)%2 == 1 && i+1 < len(lines) {
+			i++
+			record += "\n" + strings.TrimRight(lines[i], "\r")
+		}
+
+		row, err := csv.NewReader(strings.NewReader(record)).Read()
+		if err != nil {
+			l.errorf(n, "%s", unwrapCSVError(err))
+			continue
+		}
+
+		question := strings.TrimSpace(row[0])
+		switch {
+		case len(row) < 2 || strings.TrimSpace(row[1]) == "":
+			l.errorf(n, "missing answer")
+		case len(row) > 3:
+			l.errorf(n, "extra columns, expected 'question,answer' and an optional matcher")
+		case len(row) == 3:
+			if _, err := MatcherByName(strings.TrimSpace(row[2])); err != nil {
+				l.errorf(n, "%s", err)
+			}
+		}
+
+		if question == "" {
+			l.errorf(n, "missing question")
+			continue
+		}
+		if first, ok := seen[question]; ok {
+			l.errorf(n, "duplicate question %q, first seen on line %d", question, first)
+		} else {
+			seen[question] = n
+		}
+	}
+}
+
+// bank checks every problem of a JSON, YAML or TOML question file
+func (l *linter) bank(data []byte, format string) {
+	fb, err := parseBank(bytes.NewReader(data), format)
+	if err != nil {
+		line := 0
+		switch e := err.(type) {
+		case lineError:
+			line, err = e.line, e.err
+		case *json.SyntaxError:
+			line = bytes.Count(data[:e.Offset], []byte("\n")) + 1
+		}
+		l.errorf(line, "%s", err)
+		return
+	}
+
+	lines := entryLines(data, format)
+	if len(lines) != len(fb.Problems) {
+		// Better no lines than wrong ones
+		lines = nil
+	}
+	seen := make(map[string]int)
+	for i, fp := range fb.Problems {
+		// Problems which can't be tied to a line are named by number
+		line, prefix := 0, fmt.Sprintf("problem %d: ", i+1)
+		if i < len(lines) {
+			line, prefix = lines[i], ""
+		}
+		report := func(format string, args ...interface{}) {
+			l.errorf(line, prefix+format, args...)
+		}
+
+		p := fp.problem()
+		if p.Question == "" {
+			report("missing question")
+		}
+		if p.Answer == "" {
+			report("missing answer")
+		} else if err := normalizeChoices(&p); err != nil {
+			report("%s", err)
+		}
+		if p.Match != "" {
+			if _, err := MatcherByName(p.Match); err != nil {
+				report("%s", err)
+			}
+		}
+
+		if p.Question == "" {
+			continue
+		}
+		if first, ok := seen[p.Question]; !ok {
+			seen[p.Question] = i
+		} else if first < len(lines) {
+			report("duplicate question %q, first seen on line %d", p.Question, lines[first])
+		} else {
+			report("duplicate question %q, first seen in problem %d", p.Question, first+1)
+		}
+	}
+}
+
+// entryLines returns the lines the problems of a JSON, YAML or TOML
+// question file start on, as far as they can be told apart without
+// fully parsing the file
+func entryLines(data []byte, format string) []int {
+	switch format {
+	case FormatJSON:
+		return jsonEntryLines(data)
+	case FormatYAML:
+		return yamlEntryLines(data)
+	case FormatTOML:
+		return tomlEntryLines(data)
+	}
+	return nil
+}
+
+// jsonEntryLines finds the objects of the top level array, or of the
+// "problems" array of the top level object
+func jsonEntryLines(data []byte) []int {
+	var (
+		lines     []int
+		line      = 1
+		depth     int
+		listDepth = -1
+		inString  bool
+		escaped   bool
+		str, key  []byte
+	)
+
+	for _, c := range data {
+		if c == '\n' {
+			line++
+		}
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				continue
+			}
+			str = append(str, c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString, str = true, str[:0]
+		case ':':
+			if depth == 1 {
+				key = append(key[:0], str...)
+			}
+		case '{', '[':
+			if c == '{' && depth == listDepth {
+				lines = append(lines, line)
+			}
+			depth++
+			if c == '[' && (depth == 1 || depth == 2 && string(key) == "problems") {
+				listDepth = depth
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return lines
+}
+
+// yamlEntryLines finds the items of a top level sequence, or of the
+// sequence under the top level "problems" key
+func yamlEntryLines(data []byte) []int {
+	var lines []int
+	inList, sawKey := false, false
+	indent := -1
+
+	for i, l := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(l)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		item := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+		ind := len(l) - len(strings.TrimLeft(l, " "))
+
+		if ind == 0 && !item {
+			sawKey = true
+			inList = strings.HasPrefix(trimmed, "problems:")
+			indent = -1
+			continue
+		}
+		if ind == 0 && !sawKey {
+			inList = true
+		}
+		if !inList || !item {
+			continue
+		}
+		if indent < 0 {
+			indent = ind
+		}
+		if ind == indent {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// tomlEntryLines finds the sections starting a problem
+func tomlEntryLines(data []byte) []int {
+	var lines []int
+	for i, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]") && l != "[scoring]" {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// unwrapCSVError drops the position from csv errors, as lines are
+// parsed on their own and the position would be misleading
+func unwrapCSVError(err error) error {
+	if pe, ok := err.(*csv.ParseError); ok {
+		return pe.Err
+	}
+	return err
+}
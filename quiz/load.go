@@ -0,0 +1,468 @@
+package quiz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats of question files understood by Load
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// fileProblem is how a problem is written in JSON, YAML and TOML
+// question files
+type fileProblem struct {
+	Question    string   `json:"question" yaml:"question"`
+	Answer      string   `json:"answer" yaml:"answer"`
+	Type        string   `json:"type" yaml:"type"`
+	Choices     []string `json:"choices" yaml:"choices"`
+	Match       string   `json:"match" yaml:"match"`
+	TimeLimit   float64  `json:"time_limit" yaml:"time_limit"`
+	Category    string   `json:"category" yaml:"category"`
+	Difficulty  string   `json:"difficulty" yaml:"difficulty"`
+	Explanation string   `json:"explanation" yaml:"explanation"`
+
+	Points  float64            `json:"points" yaml:"points"`
+	Penalty float64            `json:"penalty" yaml:"penalty"`
+	Partial map[string]float64 `json:"partial" yaml:"partial"`
+}
+
+func (fp fileProblem) problem() Problem {
+	return Problem{
+		Question:    strings.TrimSpace(fp.Question),
+		Answer:      strings.TrimSpace(fp.Answer),
+		Type:        fp.Type,
+		Choices:     fp.Choices,
+		Match:       fp.Match,
+		TimeLimit:   time.Duration(fp.TimeLimit * float64(time.Second)),
+		Category:    fp.Category,
+		Difficulty:  fp.Difficulty,
+		Explanation: fp.Explanation,
+		Points:      fp.Points,
+		Penalty:     fp.Penalty,
+		Partial:     fp.Partial,
+	}
+}
+
+// fileBank is the top level object of JSON and YAML question files
+// which don't simply hold a list of problems
+type fileBank struct {
+	Scoring  Scoring       `json:"scoring" yaml:"scoring"`
+	Problems []fileProblem `json:"problems" yaml:"problems"`
+}
+
+func (fb fileBank) bank() Bank {
+	return Bank{Scoring: fb.Scoring, Problems: toProblems(fb.Problems)}
+}
+
+// Bank is a question file along with the settings stored in it
+type Bank struct {
+	Scoring  Scoring
+	Problems []Problem
+}
+
+// LoadFile reads the problems from the question file at path, see
+// LoadBankFile
+func LoadFile(path, format string) ([]Problem, error) {
+	b, err := LoadBankFile(path, format)
+	return b.Problems, err
+}
+
+// LoadBankFile reads the question file at path. If format is empty
+// it is detected from the file extension or its contents.
+func LoadBankFile(path, format string) (Bank, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Bank{}, err
+	}
+
+	if format == "" {
+		format = DetectFormat(path, data)
+	}
+
+	b, err := LoadBank(bytes.NewReader(data), format)
+	if err != nil {
+		return Bank{}, fmt.Errorf("%s: %s", path, err)
+	}
+	return b, nil
+}
+
+// Load parses the incoming Reader as a question file of the given
+// format and returns the problems in it
+func Load(r io.Reader, format string) ([]Problem, error) {
+	b, err := LoadBank(r, format)
+	return b.Problems, err
+}
+
+// LoadBank parses the incoming Reader as a question file of the
+// given format. Only JSON, YAML and TOML files can hold settings
+// such as the scoring.
+func LoadBank(r io.Reader, format string) (Bank, error) {
+	var (
+		b   Bank
+		err error
+	)
+
+	switch format {
+	case FormatCSV:
+		b.Problems, err = ParseProblems(r)
+	case FormatJSON, FormatYAML, FormatTOML:
+		var fb fileBank
+		fb, err = parseBank(r, format)
+		b = fb.bank()
+	default:
+		return Bank{}, fmt.Errorf("unknown question file format %q", format)
+	}
+	if err != nil {
+		return Bank{}, err
+	}
+
+	for i := range b.Problems {
+		p := &b.Problems[i]
+		if p.Question == "" {
+			return Bank{}, fmt.Errorf("problem %d: missing question", i+1)
+		}
+		if err := normalizeChoices(p); err != nil {
+			return Bank{}, fmt.Errorf("problem %d: %s", i+1, err)
+		}
+		if p.Match != "" {
+			if _, err := MatcherByName(p.Match); err != nil {
+				return Bank{}, fmt.Errorf("problem %d: %s", i+1, err)
+			}
+		}
+	}
+	return b, nil
+}
+
+// DetectFormat guesses the format of a question file, first from
+// the extension of path and then from the data itself
+func DetectFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml", ".ini":
+		return FormatTOML
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	for bytes.HasPrefix(trimmed, []byte("#")) {
+		i := bytes.IndexByte(trimmed, '\n')
+		if i < 0 {
+			break
+		}
+		trimmed = bytes.TrimSpace(trimmed[i:])
+	}
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		rest := bytes.TrimSpace(trimmed[1:])
+		if len(rest) > 0 && bytes.IndexByte([]byte(`{"]`), rest[0]) >= 0 {
+			return FormatJSON
+		}
+		return FormatTOML
+	case bytes.HasPrefix(trimmed, []byte("- ")), bytes.HasPrefix(trimmed, []byte("problems:")):
+		return FormatYAML
+	}
+	return FormatCSV
+}
+
+// parseBank parses a JSON, YAML or TOML question file as written,
+// without checking its problems
+func parseBank(r io.Reader, format string) (fileBank, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(r)
+	case FormatYAML:
+		return parseYAML(r)
+	case FormatTOML:
+		return parseTOML(r)
+	}
+	return fileBank{}, fmt.Errorf("unknown question file format %q", format)
+}
+
+func parseJSON(r io.Reader) (fileBank, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fileBank{}, err
+	}
+
+	var fb fileBank
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, &fb)
+	} else {
+		err = json.Unmarshal(data, &fb.Problems)
+	}
+	if err != nil {
+		return fileBank{}, err
+	}
+
+	return fb, nil
+}
+
+func parseYAML(r io.Reader) (fileBank, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fileBank{}, err
+	}
+
+	var fb fileBank
+	if err := yaml.Unmarshal(data, &fb.Problems); err != nil {
+		if yaml.Unmarshal(data, &fb) != nil {
+			return fileBank{}, err
+		}
+	}
+
+	return fb, nil
+}
+
+// parseTOML parses a small subset of TOML where every section
+// starts a new problem and holds 'key = value' pairs:
+//
+//	[[problem]]
+//	question = "5+5"
+//	answer = "10"
+//	category = "addition"
+//
+// Section names are ignored, so '[q1]' works just as well, except
+// for a '[scoring]' section which holds the scoring of the bank.
+func parseTOML(r io.Reader) (fileBank, error) {
+	var (
+		fb  fileBank
+		set func(key, val string) error
+	)
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if line == "[scoring]" {
+				set = fb.Scoring.set
+				continue
+			}
+			fb.Problems = append(fb.Problems, fileProblem{})
+			set = fb.Problems[len(fb.Problems)-1].set
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return fileBank{}, lineError{n, errors.New("expected 'key = value'")}
+		}
+		if set == nil {
+			return fileBank{}, lineError{n, errors.New("key outside of a section")}
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		val, err := tomlValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return fileBank{}, lineError{n, err}
+		}
+
+		if err := set(key, val); err != nil {
+			return fileBank{}, lineError{n, err}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fileBank{}, err
+	}
+
+	return fb, nil
+}
+
+// lineError is an error on a given line of a question file
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.err)
+}
+
+// tomlValue unquotes a quoted string value, leaving bare values
+// such as numbers as they are. A comment may follow the value.
+func tomlValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if err := tomlTrailing(v[end+1:]); err != nil {
+			return "", err
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.Index(v[1:], "'") + 1
+		if end < 1 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if err := tomlTrailing(v[end+1:]); err != nil {
+			return "", err
+		}
+		return v[1:end], nil
+	case strings.HasPrefix(v, "["):
+		if end := strings.LastIndex(v, "]"); end >= 0 && tomlTrailing(v[end+1:]) == nil {
+			return v[:end+1], nil
+		}
+		return v, nil
+	}
+	if i := strings.Index(v, "#"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
+
+// closingQuote returns the index of the quote ending the double
+// quoted string v starts with, -1 if there is none
+func closingQuote(v string) int {
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlTrailing checks that only a comment follows a value
+func tomlTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
+
+// tomlArray parses a single line array of strings like ["a", "b"]
+func tomlArray(v string) ([]string, error) {
+	if !strings.HasPrefix(v, "[") || !strings.HasSuffix(v, "]") {
+		return nil, fmt.Errorf("expected an array like [\"a\", \"b\"]")
+	}
+
+	csvr := csv.NewReader(strings.NewReader(v[1 : len(v)-1]))
+	csvr.TrimLeadingSpace = true
+	items, err := csvr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items, nil
+}
+
+func (fp *fileProblem) set(key, val string) error {
+	switch key {
+	case "question":
+		fp.Question = val
+	case "answer":
+		fp.Answer = val
+	case "type":
+		fp.Type = val
+	case "choices":
+		choices, err := tomlArray(val)
+		if err != nil {
+			return fmt.Errorf("invalid choices: %s", err)
+		}
+		fp.Choices = choices
+	case "match":
+		fp.Match = val
+	case "time_limit":
+		return tomlFloat(key, val, &fp.TimeLimit)
+	case "points":
+		return tomlFloat(key, val, &fp.Points)
+	case "penalty":
+		return tomlFloat(key, val, &fp.Penalty)
+	case "partial":
+		items, err := tomlArray(val)
+		if err != nil {
+			return fmt.Errorf("invalid partial: %s", err)
+		}
+		fp.Partial = make(map[string]float64)
+		for _, item := range items {
+			eq := strings.LastIndex(item, "=")
+			if eq < 0 {
+				return fmt.Errorf("invalid partial %q, expected 'answer=fraction'", item)
+			}
+			var credit float64
+			if err := tomlFloat("partial", item[eq+1:], &credit); err != nil {
+				return err
+			}
+			fp.Partial[item[:eq]] = credit
+		}
+	case "category":
+		fp.Category = val
+	case "difficulty":
+		fp.Difficulty = val
+	case "explanation":
+		fp.Explanation = val
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return nil
+}
+
+func (sc *Scoring) set(key, val string) error {
+	switch key {
+	case "penalty":
+		return tomlFloat(key, val, &sc.Penalty)
+	case "time_bonus":
+		return tomlFloat(key, val, &sc.TimeBonus)
+	case "bonus_time":
+		return tomlFloat(key, val, &sc.BonusTime)
+	case "pass_mark":
+		return tomlFloat(key, val, &sc.PassMark)
+	default:
+		return fmt.Errorf("unknown scoring key %q", key)
+	}
+}
+
+func tomlFloat(key, val string, f *float64) error {
+	v, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", key, val)
+	}
+	*f = v
+	return nil
+}
+
+func toProblems(fps []fileProblem) []Problem {
+	problems := make([]Problem, 0, len(fps))
+	for _, fp := range fps {
+		problems = append(problems, fp.problem())
+	}
+	return problems
+}
@@ -0,0 +1,89 @@
+package quiz
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Matcher reports whether the given answer matches the expected one
+type Matcher func(given, expected string) bool
+
+// Exact matches answers which are exactly the same
+func Exact(given, expected string) bool {
+	return given == expected
+}
+
+// CaseInsensitive matches answers ignoring case and differences
+// in whitespace
+func CaseInsensitive(given, expected string) bool {
+	return strings.EqualFold(normalizeSpace(given), normalizeSpace(expected))
+}
+
+// Numeric matches answers which represent the same number, so
+// 10, 10.0 and 1e1 are all equal. Non numeric answers are
+// compared case insensitively.
+func Numeric(given, expected string) bool {
+	g, gerr := strconv.ParseFloat(strings.TrimSpace(given), 64)
+	e, eerr := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+	if gerr != nil || eerr != nil {
+		return CaseInsensitive(given, expected)
+	}
+	return g == e
+}
+
+// Regex treats the expected answer as a regular expression which
+// must match the whole given answer. Invalid expressions never match.
+func Regex(given, expected string) bool {
+	re, err := regexp.Compile("^(?:" + expected + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(given)
+}
+
+// Alternatives wraps a Matcher so that the expected answer can hold
+// several accepted answers separated by '|'
+func Alternatives(m Matcher) Matcher {
+	return func(given, expected string) bool {
+		for _, alt := range strings.Split(expected, "|") {
+			if m(given, strings.TrimSpace(alt)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var matchers = map[string]Matcher{
+	"exact":   Alternatives(Exact),
+	"nocase":  Alternatives(CaseInsensitive),
+	"numeric": Alternatives(Numeric),
+	"regex":   Regex,
+}
+
+// MatcherByName returns one of the builtin matchers: exact, nocase,
+// numeric or regex. All but regex accept '|' separated alternatives.
+func MatcherByName(name string) (Matcher, error) {
+	m, ok := matchers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown matcher %q, valid ones are %s", name, strings.Join(MatcherNames(), ", "))
+	}
+	return m, nil
+}
+
+// MatcherNames returns the names of the builtin matchers
+func MatcherNames() []string {
+	names := make([]string, 0, len(matchers))
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
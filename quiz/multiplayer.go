@@ -0,0 +1,360 @@
+package quiz
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRoundLimit is the time each question of a multi-player round
+// is open for when neither the round nor the problem sets a limit
+const DefaultRoundLimit = 15 * time.Second
+
+// Round runs a quiz for several players. Every question is open for
+// a limited time and only the fastest correct answer scores.
+type Round struct {
+	problems      []Problem
+	matcher       Matcher
+	questionLimit time.Duration
+	log           io.Writer
+}
+
+// RoundOption is used with the NewRound function to configure the
+// Round returned
+type RoundOption func(*Round)
+
+// WithRoundMatcher is an option to set the Matcher used for problems
+// which don't name one themselves
+func WithRoundMatcher(m Matcher) RoundOption {
+	return func(r *Round) {
+		r.matcher = m
+	}
+}
+
+// WithRoundLog is an option to report the progress of networked
+// rounds, for the host to follow along
+func WithRoundLog(w io.Writer) RoundOption {
+	return func(r *Round) {
+		r.log = w
+	}
+}
+
+// NewRound creates a Round asking the given problems. Every question
+// is open for questionLimit, unless the problem sets its own limit.
+func NewRound(problems []Problem, questionLimit time.Duration, opts ...RoundOption) *Round {
+	if questionLimit <= 0 {
+		questionLimit = DefaultRoundLimit
+	}
+	r := &Round{
+		problems:      problems,
+		matcher:       matchers["exact"],
+		questionLimit: questionLimit,
+		log:           ioutil.Discard,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Standing is the score of a player at the end of a round
+type Standing struct {
+	Name    string
+	Score   int
+	Correct int
+}
+
+// WriteStandings writes the standings as a numbered list
+func WriteStandings(w io.Writer, standings []Standing) {
+	for i, s := range standings {
+		fmt.Fprintf(w, "%d. %s: %d points (%d correct)\n", i+1, s.Name, s.Score, s.Correct)
+	}
+}
+
+// player is a participant of a round. Players of hot seat rounds
+// share their input and output.
+type player struct {
+	Standing
+	in   *lineReader
+	out  io.Writer
+	conn net.Conn
+	gone bool
+}
+
+// attempt is the answer of a single player to a question
+type attempt struct {
+	p       *player
+	answer  string
+	correct bool
+	took    time.Duration
+}
+
+func (r *Round) limit(p Problem) time.Duration {
+	if p.TimeLimit > 0 {
+		return p.TimeLimit
+	}
+	return r.questionLimit
+}
+
+// fastest awards the point to the quickest correct attempt and
+// returns it, nil if nobody was right
+func fastest(attempts []attempt) *attempt {
+	var best *attempt
+	for i := range attempts {
+		a := &attempts[i]
+		if !a.correct {
+			continue
+		}
+		a.p.Correct++
+		if best == nil || a.took < best.took {
+			best = a
+		}
+	}
+	if best != nil {
+		best.p.Score++
+	}
+	return best
+}
+
+func standings(players []*player) []Standing {
+	s := make([]Standing, len(players))
+	for i, p := range players {
+		s[i] = p.Standing
+	}
+	sort.SliceStable(s, func(i, j int) bool {
+		if s[i].Score != s[j].Score {
+			return s[i].Score > s[j].Score
+		}
+		return s[i].Correct > s[j].Correct
+	})
+	return s
+}
+
+// HotSeat plays the round with players taking turns on a single
+// terminal. Each player gets the full time limit for their turn, and
+// the point goes to whoever answered correctly in the shortest time.
+func (r *Round) HotSeat(ctx context.Context, names []string, in io.Reader, out io.Writer) []Standing {
+	lr := newLineReader(in)
+	defer lr.close()
+	players := make([]*player, len(names))
+	for i, name := range names {
+		players[i] = &player{Standing: Standing{Name: name}, in: lr, out: out}
+	}
+
+	for i, prob := range r.problems {
+		var attempts []attempt
+
+		for _, p := range players {
+			fmt.Fprintf(out, "\n%s, your turn. Press enter when ready. ", p.Name)
+			if _, err := lr.readLine(ctx); err != nil {
+				return standings(players)
+			}
+
+			writeQuestion(out, i, prob, r.limit(prob))
+
+			qctx, cancel := context.WithTimeout(ctx, r.limit(prob))
+			start := time.Now()
+			ans, err := lr.readLine(qctx)
+			took := time.Since(start)
+			cancel()
+
+			if ctx.Err() != nil || err == io.EOF {
+				return standings(players)
+			}
+			if err != nil {
+				fmt.Fprintln(out, "\nTime's up!")
+				continue
+			}
+			attempts = append(attempts, attempt{p, ans, matches(prob, ans, r.matcher), took})
+		}
+
+		fmt.Fprintln(out, outcome(prob, fastest(attempts)))
+	}
+
+	return standings(players)
+}
+
+// Serve waits for n players to connect to l, asking each of them for
+// their name, and then plays the round with all of them at once. Every
+// player receives the same question at the same time and has until the
+// shared countdown runs out to answer it. Players can connect with
+// any line based client, like netcat or telnet.
+func (r *Round) Serve(ctx context.Context, l net.Listener, n int) ([]Standing, error) {
+	players, err := r.lobby(ctx, l, n)
+	defer func() {
+		for _, p := range players {
+			p.conn.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	r.broadcast(players, "\nEveryone is here, the round starts now!\n")
+
+	for i, prob := range r.problems {
+		if ctx.Err() != nil {
+			break
+		}
+		attempts := r.ask(ctx, players, i, prob)
+		r.broadcast(players, outcome(prob, fastest(attempts))+"\n")
+	}
+
+	s := standings(players)
+	for _, p := range players {
+		if !p.gone {
+			fmt.Fprintln(p.out, "\nFinal standings:")
+			WriteStandings(p.out, s)
+		}
+	}
+	return s, nil
+}
+
+// lobby accepts connections until n players have told their name
+func (r *Round) lobby(ctx context.Context, l net.Listener, n int) ([]*player, error) {
+	joined := make(chan *player)
+	full := make(chan struct{})
+	errs := make(chan error, 1)
+	defer close(full)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+			go r.join(ctx, conn, joined, full)
+		}
+	}()
+
+	var players []*player
+	for len(players) < n {
+		select {
+		case p := <-joined:
+			players = append(players, p)
+			fmt.Fprintf(r.log, "%s joined (%d/%d)\n", p.Name, len(players), n)
+			if len(players) < n {
+				r.broadcast(players, fmt.Sprintf("%s joined, waiting for %d more player(s).\n", p.Name, n-len(players)))
+			}
+		case err := <-errs:
+			return players, err
+		case <-ctx.Done():
+			return players, ctx.Err()
+		}
+	}
+
+	// Turn away anyone connecting from now on
+	l.Close()
+	return players, nil
+}
+
+func (r *Round) join(ctx context.Context, conn net.Conn, joined chan<- *player, full <-chan struct{}) {
+	p := &player{in: newEagerLineReader(conn), out: conn, conn: conn}
+
+	fmt.Fprint(conn, "Welcome to the quiz! Your name: ")
+	name, err := p.in.readLine(ctx)
+	if err != nil || name == "" {
+		conn.Close()
+		return
+	}
+	p.Name = name
+
+	select {
+	case joined <- p:
+	case <-full:
+		fmt.Fprintln(conn, "Sorry, the round has already started.")
+		conn.Close()
+	case <-ctx.Done():
+		conn.Close()
+	}
+}
+
+// ask sends the question to every player and collects their answers
+// until all of them answered or the countdown ran out
+func (r *Round) ask(ctx context.Context, players []*player, i int, prob Problem) []attempt {
+	limit := r.limit(prob)
+	start := time.Now()
+	qctx, cancel := context.WithDeadline(ctx, start.Add(limit))
+	defer cancel()
+
+	for _, p := range players {
+		if !p.gone {
+			writeQuestion(p.out, i, prob, limit)
+		}
+	}
+	fmt.Fprintf(r.log, "Question #%d: %s\n", i+1, prob.Question)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		attempts []attempt
+	)
+	for _, p := range players {
+		if p.gone {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *player) {
+			defer wg.Done()
+
+			ans, at, err := p.in.readLineSince(qctx, start)
+			if err == io.EOF {
+				p.gone = true
+				fmt.Fprintf(r.log, "%s left\n", p.Name)
+				return
+			}
+			if err != nil {
+				fmt.Fprintln(p.out, "\nTime's up!")
+				return
+			}
+
+			fmt.Fprintln(p.out, "Answer received, waiting for the others.")
+			mu.Lock()
+			attempts = append(attempts, attempt{p, ans, matches(prob, ans, r.matcher), at.Sub(start)})
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	return attempts
+}
+
+func (r *Round) broadcast(players []*player, msg string) {
+	for _, p := range players {
+		if !p.gone {
+			fmt.Fprint(p.out, msg)
+		}
+	}
+}
+
+// writeQuestion writes the i-th problem along with its choices and
+// the time there is to answer it
+func writeQuestion(w io.Writer, i int, p Problem, limit time.Duration) {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\nQuestion #%d (%s): %s\n", i+1, limit, p.Question)
+	for j, c := range p.Choices {
+		fmt.Fprintf(bw, "  %s) %s\n", letter(j), c)
+	}
+	fmt.Fprint(bw, "> ")
+	bw.Flush()
+}
+
+func outcome(p Problem, best *attempt) string {
+	if best == nil {
+		return fmt.Sprintf("Nobody got it, the answer was %s.", p.Answer)
+	}
+	ans := best.answer
+	if c, ok := p.choice(ans); ok {
+		ans = c
+	}
+	return fmt.Sprintf("%s was the fastest with %s in %s!", best.p.Name, ans, best.took.Round(time.Millisecond))
+}
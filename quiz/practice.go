@@ -0,0 +1,164 @@
+package quiz
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Card is the spaced repetition state of a single problem, as
+// scheduled by the SM-2 algorithm
+type Card struct {
+	Ease      float64   `json:"ease"`
+	Interval  int       `json:"interval_days"`
+	Reps      int       `json:"reps"`
+	Lapses    int       `json:"lapses"`
+	Due       time.Time `json:"due"`
+	Reviewed  time.Time `json:"reviewed"`
+	LastWrong time.Time `json:"last_wrong"`
+}
+
+// Deck holds the cards of every problem practiced so far, keyed by
+// question, and persists them to a local JSON state file
+type Deck struct {
+	path  string
+	Cards map[string]*Card `json:"cards"`
+}
+
+// LoadDeck loads the deck stored at path, or returns an empty one
+// if the file doesn't exist yet
+func LoadDeck(path string) (*Deck, error) {
+	d := &Deck{path: path, Cards: make(map[string]*Card)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	if d.Cards == nil {
+		d.Cards = make(map[string]*Card)
+	}
+	return d, nil
+}
+
+// Save writes the deck back to its state file
+func (d *Deck) Save() error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := d.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// Schedule orders the problems for a practice session: problems due
+// for review come first, most overdue first, followed by problems
+// which were never practiced and then the ones due later on
+func (d *Deck) Schedule(problems []Problem, now time.Time) []Problem {
+	const (
+		due = iota
+		fresh
+		later
+	)
+	rank := func(p Problem) (int, time.Time) {
+		c, ok := d.Cards[p.Question]
+		switch {
+		case !ok:
+			return fresh, time.Time{}
+		case !c.Due.After(now):
+			return due, c.Due
+		default:
+			return later, c.Due
+		}
+	}
+
+	scheduled := make([]Problem, len(problems))
+	copy(scheduled, problems)
+
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		ri, ti := rank(scheduled[i])
+		rj, tj := rank(scheduled[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ti.Before(tj)
+	})
+	return scheduled
+}
+
+// Due returns how many of the problems are due for review, counting
+// problems which were never practiced as due
+func (d *Deck) Due(problems []Problem, now time.Time) int {
+	n := 0
+	for _, p := range problems {
+		if c, ok := d.Cards[p.Question]; !ok || !c.Due.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Review updates the card of a problem after it was answered with
+// the given quality, from 0 (blackout) to 5 (perfect recall)
+func (d *Deck) Review(p Problem, quality int, now time.Time) {
+	c, ok := d.Cards[p.Question]
+	if !ok {
+		c = &Card{Ease: 2.5}
+		d.Cards[p.Question] = c
+	}
+
+	if quality >= 3 {
+		switch c.Reps {
+		case 0:
+			c.Interval = 1
+		case 1:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+		c.Reps++
+	} else {
+		c.Reps = 0
+		c.Interval = 1
+		c.Lapses++
+		c.LastWrong = now
+	}
+
+	q := float64(5 - quality)
+	c.Ease += 0.1 - q*(0.08+q*0.02)
+	if c.Ease < 1.3 {
+		c.Ease = 1.3
+	}
+
+	c.Reviewed = now
+	c.Due = now.AddDate(0, 0, c.Interval)
+}
+
+// Quality grades an answer for Review: correct answers score 4,
+// or 5 when given within five seconds, wrong answers 1 and questions
+// which timed out without an answer 0
+func Quality(a Answer) int {
+	switch {
+	case a.TimedOut:
+		return 0
+	case a.Correct && a.Duration < 5*time.Second:
+		return 5
+	case a.Correct:
+		return 4
+	default:
+		return 1
+	}
+}
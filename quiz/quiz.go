@@ -1,80 +1,364 @@
-package main
+package quiz
 
 import (
+	"context"
 	"encoding/csv"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
-	"os"
 	"strings"
 	"time"
 )
 
-var (
-	csvPath string
-	limit   int
-	shuffle bool
-)
+// Problem represents a single question along with its expected answer
+type Problem struct {
+	Question string
+	// Answer is the expected answer. For choice and true or false
+	// problems it is the text of the correct choice.
+	Answer string
+	// Type is one of TypeText, TypeChoice or TypeTrueFalse
+	Type    string
+	Choices []string
+	// Match is the name of the matcher used to check answers to this
+	// problem, empty means the quiz wide matcher is used
+	Match string
+	// TimeLimit is the time allowed for answering this problem,
+	// zero means the quiz wide question limit applies
+	TimeLimit time.Duration
+
+	Category    string
+	Difficulty  string
+	Explanation string
 
-func init() {
-	flag.StringVar(&csvPath, "csv", "problems.csv", `a csv file in the format of 'questions,answers'`)
-	flag.IntVar(&limit, "limit", 30, "time limit for the quiz in seconds")
-	flag.BoolVar(&shuffle, "shuffle", false, "shuffle questions before asking")
+	// Points is what a correct answer is worth, 1 if not set
+	Points float64
+	// Penalty is subtracted for a wrong answer, overriding the
+	// penalty of the quiz when set
+	Penalty float64
+	// Partial maps partially correct answers to the fraction of
+	// the points they are worth
+	Partial map[string]float64
 }
 
-func askQuestions(questions [][]string, correctAnswers *int, exit chan bool) {
-	var ans string
-	for i, row := range questions {
-		fmt.Printf("Question #%d: %s = ", i+1, row[0])
-		fmt.Scanln(&ans)
+// ParseProblems parses the incoming Reader as CSV in the format
+// of 'questions,answers' and returns the problems in it. An optional
+// third column names the matcher used for that row, see MatcherByName.
+func ParseProblems(r io.Reader) ([]Problem, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+
+	rows, err := csvr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
 
-		if strings.TrimSpace(ans) == row[1] {
-			*correctAnswers++
+	problems := make([]Problem, 0, len(rows))
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("line %d: expected 'question,answer'", i+1)
 		}
+		p := Problem{
+			Question: strings.TrimSpace(row[0]),
+			Answer:   strings.TrimSpace(row[1]),
+			Type:     TypeText,
+		}
+		if len(row) > 2 {
+			p.Match = strings.TrimSpace(row[2])
+			if _, err := MatcherByName(p.Match); err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		}
+		problems = append(problems, p)
 	}
-	exit <- true
+
+	return problems, nil
+}
+
+// Shuffle shuffles the problems in place
+func Shuffle(problems []Problem) {
+	rand.Shuffle(len(problems), func(i, j int) { problems[i], problems[j] = problems[j], problems[i] })
 }
 
-func timer(start chan bool, exit chan bool) {
-	fmt.Printf("Time limit for the quiz: %ds\nPress enter to start the quiz. ", limit)
-	fmt.Scanln()
-	start <- true
+// Quiz asks a list of problems over an io.Reader and io.Writer
+// pair and keeps track of the answers
+type Quiz struct {
+	problems      []Problem
+	in            *lineReader
+	out           io.Writer
+	questionLimit time.Duration
+	matcher       Matcher
+	timeLimit     time.Duration
+	deadline      time.Time
+	pauseLeft     time.Duration
+	scoring       Scoring
 
-	time.Sleep(time.Duration(limit) * time.Second)
-	exit <- true
+	// adaptive quizzes pick problems by difficulty, q.level indexes
+	// the sorted difficulty levels of the problems
+	adaptive bool
+	levels   []int
+	level    int
+	streak   int
 }
 
-func main() {
-	flag.Parse()
+// Option is used with the New function to configure the Quiz
+type Option func(*Quiz)
 
-	file, err := os.Open(csvPath)
-	if err != nil {
-		fmt.Println(err)
-		return
+// New creates a Quiz which asks the given problems on out and
+// reads the answers line by line from in. The quiz reads from in in
+// the background, only when it waits for an answer, so in belongs to
+// the quiz until it is closed with Close.
+func New(problems []Problem, in io.Reader, out io.Writer, opts ...Option) *Quiz {
+	q := &Quiz{
+		problems: problems,
+		in:       newLineReader(in),
+		out:      out,
+		matcher:  matchers["exact"],
 	}
-	defer file.Close()
 
-	csvr := csv.NewReader(file)
-	questions, err := csvr.ReadAll()
-	if err != nil {
-		fmt.Println(err)
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// WithQuestionLimit is an option to limit the time allowed for
+// answering each question. Problems with their own TimeLimit
+// override it.
+func WithQuestionLimit(d time.Duration) Option {
+	return func(q *Quiz) {
+		q.questionLimit = d
+	}
+}
+
+// WithMatcher is an option to set the Matcher used for problems
+// which don't name one themselves
+func WithMatcher(m Matcher) Option {
+	return func(q *Quiz) {
+		q.matcher = m
+	}
+}
+
+// WithTimeLimit is an option to limit the time allowed for the
+// whole quiz. Unlike a context deadline the clock of the quiz stops
+// while it is paused.
+func WithTimeLimit(d time.Duration) Option {
+	return func(q *Quiz) {
+		q.timeLimit = d
+	}
+}
+
+// WithPause is an option which lets the player pause the quiz by
+// answering PauseCommand. The clock stops while the quiz is paused,
+// for up to max in total over the whole quiz.
+func WithPause(max time.Duration) Option {
+	return func(q *Quiz) {
+		q.pauseLeft = max
+	}
+}
+
+// PauseCommand is the answer which pauses the quiz, see WithPause
+const PauseCommand = ":pause"
+
+// Run asks every problem, in order unless the quiz is adaptive,
+// until all of them are answered,
+// the time is up, the input is exhausted or the context is done.
+// The result is returned in all of these cases, with End telling
+// them apart. The error is only non-nil if reading the input failed.
+func (q *Quiz) Run(ctx context.Context) (Result, error) {
+	res := Result{Total: len(q.problems), Answers: make([]Answer, len(q.problems))}
+	for i, p := range q.problems {
+		res.Answers[i] = Answer{Question: p.Question, Expected: p.Answer, Category: p.Category}
+	}
+
+	if q.timeLimit > 0 {
+		q.deadline = time.Now().Add(q.timeLimit)
+	}
+
+	end, err := q.run(ctx, &res)
+	res.End = end
+	q.scoring.total(&res, q.problems)
+	return res, err
+}
+
+// run asks the problems, recording the answer to q.problems[i] in
+// res.Answers[i] whatever order they are asked in
+func (q *Quiz) run(ctx context.Context, res *Result) (string, error) {
+	asked := make([]bool, len(q.problems))
+
+	for n := 0; n < len(q.problems); n++ {
+		i := q.next(asked)
+		p := q.problems[i]
+		q.prompt(n, p)
+
+		a := &res.Answers[i]
+		ans, err := q.ask(ctx, p, a)
+		switch {
+		case err == errTimeout:
+			a.TimedOut = true
+			asked[i] = true
+			q.adapt(false)
+			fmt.Fprintln(q.out, "\nTime's up for this question!")
+			continue
+		case err == errTimeUp:
+			return EndTimeUp, nil
+		case ctx.Err() != nil:
+			return EndInterrupted, nil
+		case err == io.EOF:
+			return EndNoInput, nil
+		case err != nil:
+			return EndNoInput, err
+		}
+
+		if ans == PauseCommand && q.pauseLeft > 0 {
+			if err := q.pause(ctx); err == io.EOF {
+				return EndNoInput, nil
+			} else if err != nil {
+				return EndInterrupted, nil
+			}
+			n-- // ask the same problem again
+			continue
+		}
+
+		a.Given = ans
+		if c, ok := p.choice(ans); ok {
+			a.Given = c
+		}
+		a.Answered = true
+		asked[i] = true
+		if matches(p, ans, q.matcher) {
+			a.Correct = true
+			res.Correct++
+		} else {
+			res.Wrong++
+		}
+
+		a.Points = q.scoring.points(p, *a, q.matcher)
+		res.Score += a.Points
+		q.adapt(a.Correct)
+	}
+
+	return EndCompleted, nil
+}
+
+// Close stops the quiz from reading its input. A line the quiz was
+// waiting for when Run returned, such as the answer to a question
+// which timed out, is still read from the input and dropped, after
+// which in can be used by the caller again.
+func (q *Quiz) Close() error {
+	q.in.close()
+	return nil
+}
+
+// next returns the index of the next problem to ask
+func (q *Quiz) next(asked []bool) int {
+	if q.adaptive {
+		return q.adaptiveNext(asked)
+	}
+	for i := range asked {
+		if !asked[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// pause stops the clock of the quiz until the player presses enter
+// or the pause time left runs out, and then pushes the deadline back
+// by the time spent paused
+func (q *Quiz) pause(ctx context.Context) error {
+	fmt.Fprintf(q.out, "Paused, %s of pause time left. Press enter to resume. ", q.pauseLeft.Round(time.Second))
+
+	pctx, cancel := context.WithTimeout(ctx, q.pauseLeft)
+	defer cancel()
+
+	start := time.Now()
+	_, err := q.in.readLine(pctx)
+	paused := time.Since(start)
+
+	if ctx.Err() != nil || err == io.EOF {
+		return err
+	}
+	if pctx.Err() != nil {
+		paused = q.pauseLeft
+		fmt.Fprintln(q.out, "\nOut of pause time, resuming.")
+	}
+
+	q.pauseLeft -= paused
+	if !q.deadline.IsZero() {
+		q.deadline = q.deadline.Add(paused)
+	}
+	return nil
+}
+
+// prompt writes the i-th problem along with its choices, if any
+func (q *Quiz) prompt(i int, p Problem) {
+	if len(p.Choices) == 0 {
+		fmt.Fprintf(q.out, "Question #%d: %s = ", i+1, p.Question)
 		return
 	}
 
-	if shuffle {
-		rand.Seed(time.Now().UnixNano())
-		rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	fmt.Fprintf(q.out, "Question #%d: %s\n", i+1, p.Question)
+	for j, c := range p.Choices {
+		fmt.Fprintf(q.out, "  %s) %s\n", letter(j), c)
+	}
+	fmt.Fprint(q.out, "Your choice: ")
+}
+
+// matches checks ans against the problem using its own matcher if it
+// names a valid one, or m otherwise. Answers to choice problems must
+// resolve to the correct choice.
+func matches(p Problem, ans string, m Matcher) bool {
+	if len(p.Choices) > 0 {
+		c, ok := p.choice(ans)
+		return ok && c == p.Answer
+	}
+
+	if pm, err := MatcherByName(p.Match); p.Match != "" && err == nil {
+		m = pm
+	}
+	return m(ans, p.Answer)
+}
+
+var (
+	errTimeout = errors.New("question timed out")
+	errTimeUp  = errors.New("quiz timed out")
+)
+
+// ask waits for the answer to a single problem, honouring both its
+// time limit and the one of the quiz. The time taken is added to
+// a.Duration, so a problem asked again after a pause only gets the
+// rest of its time.
+func (q *Quiz) ask(ctx context.Context, p Problem, a *Answer) (string, error) {
+	limit := p.TimeLimit
+	if limit == 0 {
+		limit = q.questionLimit
 	}
 
-	var correctAnswers int
-	exit := make(chan bool)
-	start := make(chan bool)
+	deadline := q.deadline
+	if limit > 0 {
+		qd := time.Now().Add(limit - a.Duration)
+		if deadline.IsZero() || qd.Before(deadline) {
+			deadline = qd
+		}
+	}
 
-	go timer(start, exit)
+	qctx := ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		qctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
 
-	<-start
-	go askQuestions(questions, &correctAnswers, exit)
+	start := time.Now()
+	ans, err := q.in.readLine(qctx)
+	a.Duration += time.Since(start)
 
-	<-exit
-	fmt.Printf("\nYou scored %d out of %d.\n", correctAnswers, len(questions))
+	if err != nil && ctx.Err() == nil && qctx.Err() != nil {
+		if !q.deadline.IsZero() && !time.Now().Before(q.deadline) {
+			return "", errTimeUp
+		}
+		return "", errTimeout
+	}
+	return ans, err
 }
@@ -0,0 +1,107 @@
+package quiz
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Reasons for a quiz run to end
+const (
+	EndCompleted   = "completed"
+	EndTimeUp      = "time_up"
+	EndInterrupted = "interrupted"
+	EndNoInput     = "no_input"
+)
+
+// Result holds the outcome of a quiz run
+type Result struct {
+	Correct int `json:"correct"`
+	Wrong   int `json:"wrong"`
+	Total   int `json:"total"`
+	// Score is the sum of the points of all answers, out of MaxScore
+	Score    float64 `json:"score"`
+	MaxScore float64 `json:"max_score"`
+	PassMark float64 `json:"pass_mark"`
+	Passed   bool    `json:"passed"`
+	// End is the reason the quiz ended, one of the End constants
+	End     string   `json:"end"`
+	Answers []Answer `json:"answers"`
+}
+
+// Unanswered returns the number of problems which were not answered,
+// either because they timed out or were never asked
+func (r Result) Unanswered() int {
+	return r.Total - r.Correct - r.Wrong
+}
+
+// Answer records how a single problem was answered
+type Answer struct {
+	Question string        `json:"question"`
+	Given    string        `json:"given"`
+	Expected string        `json:"expected"`
+	Category string        `json:"category,omitempty"`
+	Correct  bool          `json:"correct"`
+	Answered bool          `json:"answered"`
+	TimedOut bool          `json:"timed_out"`
+	Points   float64       `json:"points"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// WriteJSON writes the detailed report of the result as JSON
+func (r Result) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// WriteCSV writes one row per question with the given and expected
+// answers, correctness and the time taken in seconds
+func (r Result) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"question", "given", "expected", "correct", "answered", "timed_out", "points", "seconds"})
+
+	for _, a := range r.Answers {
+		cw.Write([]string{
+			a.Question,
+			a.Given,
+			a.Expected,
+			strconv.FormatBool(a.Correct),
+			strconv.FormatBool(a.Answered),
+			strconv.FormatBool(a.TimedOut),
+			strconv.FormatFloat(a.Points, 'f', -1, 64),
+			strconv.FormatFloat(a.Duration.Seconds(), 'f', 3, 64),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteText writes a human readable table of the result
+func (r Result) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tQUESTION\tGIVEN\tEXPECTED\tRESULT\tPOINTS\tTIME")
+
+	for i, a := range r.Answers {
+		status := "wrong"
+		switch {
+		case a.Correct:
+			status = "correct"
+		case a.Points > 0:
+			status = "partial"
+		case a.TimedOut:
+			status = "timed out"
+		case !a.Answered:
+			status = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%g\t%s\n",
+			i+1, a.Question, a.Given, a.Expected, status, a.Points, a.Duration.Round(time.Millisecond))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,94 @@
+package quiz
+
+import (
+	"math"
+	"time"
+)
+
+// Scoring configures how the answers of a quiz are turned into a
+// score. The zero value scores every problem by its points alone.
+type Scoring struct {
+	// Penalty is subtracted for every wrong answer to a problem which
+	// doesn't set a penalty of its own. Unanswered problems are never
+	// penalised.
+	Penalty float64 `json:"penalty" yaml:"penalty"`
+	// TimeBonus is the fraction of its points awarded on top for a
+	// correct answer given within BonusTime seconds
+	TimeBonus float64 `json:"time_bonus" yaml:"time_bonus"`
+	BonusTime float64 `json:"bonus_time" yaml:"bonus_time"`
+	// PassMark is the percentage of the maximum score needed to pass,
+	// zero means every result passes
+	PassMark float64 `json:"pass_mark" yaml:"pass_mark"`
+}
+
+// WithScoring is an option to set how the answers are scored
+func WithScoring(s Scoring) Option {
+	return func(q *Quiz) {
+		q.scoring = s
+	}
+}
+
+// points returns the points an answer to p is worth: its full points
+// plus any time bonus when correct, partial credit when the answer
+// is one of the partially correct ones, and the penalty when wrong.
+// An answer matching several partially correct ones gets the highest
+// credit of them.
+func (s Scoring) points(p Problem, a Answer, m Matcher) float64 {
+	switch {
+	case a.Correct:
+		pts := p.points()
+		if s.TimeBonus > 0 && a.Duration <= time.Duration(s.BonusTime*float64(time.Second)) {
+			pts += pts * s.TimeBonus
+		}
+		return pts
+	case !a.Answered:
+		return 0
+	}
+
+	best, partial := 0.0, false
+	for ans, credit := range p.Partial {
+		if matches(Problem{Answer: ans, Match: p.Match}, a.Given, m) && (!partial || credit > best) {
+			best, partial = credit, true
+		}
+	}
+	if partial {
+		return p.points() * best
+	}
+
+	penalty := s.Penalty
+	if p.Penalty != 0 {
+		penalty = p.Penalty
+	}
+	if penalty == 0 {
+		return 0
+	}
+	return -penalty
+}
+
+// total fills in the maximum score of the result and whether it
+// passed, once all answers are scored
+func (s Scoring) total(res *Result, problems []Problem) {
+	res.MaxScore = 0
+	for _, p := range problems {
+		res.MaxScore += p.points()
+	}
+
+	res.PassMark = s.PassMark
+	res.Passed = s.PassMark <= 0 || res.Percent() >= s.PassMark
+}
+
+// Percent returns the score as a percentage of the maximum score
+func (r Result) Percent() float64 {
+	if r.MaxScore == 0 {
+		return 0
+	}
+	return math.Max(0, r.Score/r.MaxScore*100)
+}
+
+// points returns the points the problem is worth, 1 unless set
+func (p Problem) points() float64 {
+	if p.Points == 0 {
+		return 1
+	}
+	return p.Points
+}
@@ -0,0 +1,450 @@
+package quiz
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var serverTpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"letter": letter,
+	"inc":    func(i int) int { return i + 1 },
+	"round":  func(d time.Duration) time.Duration { return d.Round(time.Second) },
+}).Parse(serverTemplates))
+
+// Server serves a quiz over HTTP so that many players can take it
+// at the same time from their browsers. Every player gets a session
+// with its own timer, which is enforced on the server: answers given
+// after the deadline are not counted, just like timer() does for the
+// command line quiz.
+type Server struct {
+	problems      []Problem
+	limit         time.Duration
+	questionLimit time.Duration
+	matcher       Matcher
+	board         *Leaderboard
+	mux           *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// ServerOption is used with the NewServer function to configure
+// the Server returned
+type ServerOption func(*Server)
+
+// WithLeaderboard is an option to record scores in the given
+// leaderboard, for example one persisted to a file
+func WithLeaderboard(b *Leaderboard) ServerOption {
+	return func(s *Server) {
+		s.board = b
+	}
+}
+
+// WithServerMatcher is an option to set the Matcher used for
+// problems which don't name one themselves
+func WithServerMatcher(m Matcher) ServerOption {
+	return func(s *Server) {
+		s.matcher = m
+	}
+}
+
+// WithServerQuestionLimit is an option to limit the time allowed for
+// answering each question. Problems with their own TimeLimit
+// override it.
+func WithServerQuestionLimit(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.questionLimit = d
+	}
+}
+
+// NewServer creates a Server asking the given problems, where every
+// session has to be finished within limit. A limit of zero means no
+// time limit.
+func NewServer(problems []Problem, limit time.Duration, opts ...ServerOption) *Server {
+	s := &Server{
+		problems: problems,
+		limit:    limit,
+		matcher:  matchers["exact"],
+		sessions: make(map[string]*session),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.board == nil {
+		s.board, _ = NewLeaderboard("")
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/start", s.start)
+	s.mux.HandleFunc("/play/", s.play)
+	s.mux.HandleFunc("/leaderboard", s.leaderboard)
+	s.mux.HandleFunc("/api/leaderboard", s.leaderboardJSON)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// sessionTTL is how long finished sessions are kept around so
+// players can look at their results. Sessions without a time limit
+// are given up on after as long without an answer.
+const sessionTTL = 24 * time.Hour
+
+// session is the state of a single player taking the quiz
+type session struct {
+	name     string
+	started  time.Time
+	deadline time.Time
+	asked    time.Time
+	current  int
+	result   Result
+	done     bool
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.render(w, "index", map[string]interface{}{
+		"Total": len(s.problems),
+		"Limit": s.limit,
+	})
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(s.problems) == 0 {
+		http.Error(w, "This quiz has no questions", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "A name is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	sess := &session{
+		name:    name,
+		started: now,
+		asked:   now,
+		result:  Result{Total: len(s.problems), Answers: make([]Answer, len(s.problems))},
+	}
+	if s.limit > 0 {
+		sess.deadline = now.Add(s.limit)
+	}
+	for i, p := range s.problems {
+		sess.result.Answers[i] = Answer{Question: p.Question, Expected: p.Answer, Category: p.Category}
+	}
+
+	s.mu.Lock()
+	s.sweep(now)
+	s.sessions[id] = sess
+	s.mu.Unlock()
+
+	http.Redirect(w, r, "/play/"+id, http.StatusSeeOther)
+}
+
+func (s *Server) play(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/play/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	s.expire(sess, now)
+
+	if r.Method == http.MethodPost {
+		// Ignore resubmitted forms for questions already answered
+		n, err := strconv.Atoi(r.FormValue("question"))
+		if err == nil && n == sess.current && !sess.done {
+			s.answer(sess, r.FormValue("answer"), now)
+		}
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	if sess.done {
+		s.render(w, "result", map[string]interface{}{
+			"Name":     sess.name,
+			"Result":   sess.result,
+			"Duration": sess.duration(now),
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"Name":    sess.name,
+		"Index":   sess.current,
+		"Total":   len(s.problems),
+		"Problem": s.problems[sess.current],
+	}
+	if qd := s.questionDeadline(sess); !qd.IsZero() {
+		data["Remaining"] = int(qd.Sub(now).Seconds()) + 1
+	}
+	s.render(w, "question", data)
+}
+
+// questionDeadline returns the time by which the current question of
+// the session has to be answered, the zero time if there is none
+func (s *Server) questionDeadline(sess *session) time.Time {
+	deadline := sess.deadline
+
+	limit := s.problems[sess.current].TimeLimit
+	if limit == 0 {
+		limit = s.questionLimit
+	}
+	if limit > 0 {
+		qd := sess.asked.Add(limit)
+		if deadline.IsZero() || qd.Before(deadline) {
+			deadline = qd
+		}
+	}
+	return deadline
+}
+
+// sweep finishes the sessions whose time ran out or which were
+// abandoned, adding them to the leaderboard, and forgets the sessions
+// finished more than sessionTTL ago. It must be called with s.mu held.
+func (s *Server) sweep(now time.Time) {
+	for id, sess := range s.sessions {
+		s.expire(sess, now)
+		if !sess.done && now.Sub(sess.asked) > sessionTTL {
+			sess.result.End = EndNoInput
+			s.finish(sess, now)
+		}
+		if sess.done && now.Sub(sess.started) > sessionTTL {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// expire finishes the session if its deadline has passed, or moves
+// on past the questions which timed out since they were asked
+func (s *Server) expire(sess *session, now time.Time) {
+	for !sess.done {
+		if !sess.deadline.IsZero() && !now.Before(sess.deadline) {
+			sess.result.End = EndTimeUp
+			s.finish(sess, now)
+			return
+		}
+
+		qd := s.questionDeadline(sess)
+		if qd.IsZero() || now.Before(qd) {
+			return
+		}
+		a := &sess.result.Answers[sess.current]
+		a.Duration = qd.Sub(sess.asked)
+		a.TimedOut = true
+		// The next question was asked when this one timed out
+		s.next(sess, qd)
+	}
+}
+
+func (s *Server) answer(sess *session, ans string, now time.Time) {
+	p := s.problems[sess.current]
+	ans = strings.TrimSpace(ans)
+
+	a := &sess.result.Answers[sess.current]
+	a.Given = ans
+	if c, ok := p.choice(ans); ok {
+		a.Given = c
+	}
+	a.Answered = true
+	a.Duration = now.Sub(sess.asked)
+	if matches(p, ans, s.matcher) {
+		a.Correct = true
+		sess.result.Correct++
+	} else {
+		sess.result.Wrong++
+	}
+
+	s.next(sess, now)
+}
+
+func (s *Server) next(sess *session, now time.Time) {
+	sess.current++
+	sess.asked = now
+	if sess.current >= len(s.problems) {
+		sess.result.End = EndCompleted
+		s.finish(sess, now)
+	}
+}
+
+func (s *Server) finish(sess *session, now time.Time) {
+	sess.done = true
+	err := s.board.Add(Score{
+		Name:     sess.name,
+		Correct:  sess.result.Correct,
+		Total:    sess.result.Total,
+		Duration: sess.duration(now),
+		Date:     now,
+	})
+	if err != nil {
+		log.Print("Error saving leaderboard: ", err)
+	}
+}
+
+// duration returns how long the session took, capped at its deadline
+func (sess *session) duration(now time.Time) time.Duration {
+	if !sess.deadline.IsZero() && now.After(sess.deadline) {
+		now = sess.deadline
+	}
+	return now.Sub(sess.started)
+}
+
+func (s *Server) leaderboard(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "leaderboard", s.board.Top(0))
+}
+
+func (s *Server) leaderboardJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.board.Top(0))
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data interface{}) {
+	err := serverTpl.ExecuteTemplate(w, name, data)
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var serverTemplates = `
+{{ define "header" }}
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>Quiz</title>
+  <style>
+    body {
+      font-family: Helvetica, sans-serif;
+      display: flex;
+      flex-direction: column;
+      align-items: center;
+      padding: 50px;
+    }
+    table {
+      border-collapse: collapse;
+    }
+    td, th {
+      padding: 4px 12px;
+      text-align: left;
+    }
+    .wrong {
+      color: #b00;
+    }
+  </style>
+{{ end }}
+
+{{ define "index" }}
+{{ template "header" }}
+</head>
+<body>
+  <h1>Quiz</h1>
+  <p>{{ .Total }} questions{{ if .Limit }}, {{ .Limit }} to answer them{{ end }}.</p>
+  <form method="post" action="/start">
+    <input name="name" placeholder="Your name" required autofocus>
+    <button type="submit">Start</button>
+  </form>
+  <a href="/leaderboard">Leaderboard</a>
+</body>
+</html>
+{{ end }}
+
+{{ define "question" }}
+{{ template "header" }}
+  {{ if .Remaining }}<meta http-equiv="refresh" content="{{ .Remaining }}">{{ end }}
+</head>
+<body>
+  <h2>Question #{{ inc .Index }} of {{ .Total }}</h2>
+  {{ if .Remaining }}<p>{{ .Remaining }}s left</p>{{ end }}
+  <form method="post">
+    <input type="hidden" name="question" value="{{ .Index }}">
+    <p>{{ .Problem.Question }}</p>
+    {{ if .Problem.Choices }}
+      {{ range $i, $c := .Problem.Choices }}
+        <label><input type="radio" name="answer" value="{{ letter $i }}"> {{ letter $i }}) {{ $c }}</label><br>
+      {{ end }}
+    {{ else }}
+      <input name="answer" autocomplete="off" autofocus>
+    {{ end }}
+    <button type="submit">Answer</button>
+  </form>
+</body>
+</html>
+{{ end }}
+
+{{ define "result" }}
+{{ template "header" }}
+</head>
+<body>
+  <h1>{{ .Name }}, you scored {{ .Result.Correct }} out of {{ .Result.Total }}</h1>
+  <p>in {{ round .Duration }}</p>
+  <table>
+    <tr><th>Question</th><th>Given</th><th>Expected</th></tr>
+    {{ range .Result.Answers }}
+      <tr{{ if not .Correct }} class="wrong"{{ end }}>
+        <td>{{ .Question }}</td><td>{{ .Given }}</td><td>{{ .Expected }}</td>
+      </tr>
+    {{ end }}
+  </table>
+  <p><a href="/leaderboard">Leaderboard</a> | <a href="/">Play again</a></p>
+</body>
+</html>
+{{ end }}
+
+{{ define "leaderboard" }}
+{{ template "header" }}
+</head>
+<body>
+  <h1>Leaderboard</h1>
+  <table>
+    <tr><th>#</th><th>Name</th><th>Score</th><th>Time</th></tr>
+    {{ range $i, $s := . }}
+      <tr><td>{{ inc $i }}</td><td>{{ $s.Name }}</td><td>{{ $s.Correct }}/{{ $s.Total }}</td><td>{{ round $s.Duration }}</td></tr>
+    {{ end }}
+  </table>
+  <p><a href="/">Play</a></p>
+</body>
+</html>
+{{ end }}
+`
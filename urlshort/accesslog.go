@@ -0,0 +1,42 @@
+package urlshort
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccessLog wraps h, writing a line in the combined log format to w
+// for every request, followed by the time taken to answer it
+func AccessLog(h http.Handler, w io.Writer) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: rw}
+		h.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s - - [%s] %q %d %d %q %q %s\n",
+			host,
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+r.URL.RequestURI()+" "+r.Proto,
+			sw.status,
+			sw.size,
+			r.Referer(),
+			r.UserAgent(),
+			time.Since(start).Round(time.Microsecond),
+		)
+	})
+}
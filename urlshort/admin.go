@@ -0,0 +1,344 @@
+package urlshort
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Generated short codes are codeLength characters from codeAlphabet.
+// A new code is tried up to codeAttempts times when one is taken.
+const (
+	codeLength   = 6
+	codeAttempts = 10
+	codeAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// AdminHandler returns an http.Handler serving a JSON API to manage
+// the links of a LinkStore. Every request must carry the token as
+// "Authorization: Bearer <token>". The API serves:
+//
+//	GET    /links          list all links
+//	POST   /links          create a link from {"path": ..., "url": ...}
+//	GET    /links/<path>   get a single link
+//	PUT    /links/<path>   replace a link with {"url": ..., "status": ...}
+//	DELETE /links/<path>   delete a link
+//	GET    /stats          hit statistics of all links
+//	GET    /stats/<path>   hit statistics of a single link
+//	GET    /ratelimit      counters of the rate limiters, by name
+//
+// Links can set all the optional fields of Link. When creating a link
+// without a path, a random short code is used. Links scoped to a host
+// and their statistics are addressed by adding ?host=<host> to their
+// URL in the API.
+//
+// Links are validated with the options given with WithValidation, as
+// well as checked for loops with the links in the store, the host the
+// API is reached on counting as a short host. Statistics are only
+// served when the store is a HitStore as well. Rate limiters given
+// with WithAdminRateLimiter have their counters served. Use
+// http.StripPrefix to serve the API below a prefix.
+func AdminHandler(s LinkStore, token string, opts ...AdminOption) http.Handler {
+	a := &admin{
+		store:    s,
+		token:    token,
+		opts:     newOptions(nil),
+		limiters: make(map[string]*RateLimiter),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+type admin struct {
+	store    LinkStore
+	token    string
+	opts     *options
+	limiters map[string]*RateLimiter
+}
+
+func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="urlshort"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/links":
+		switch r.Method {
+		case http.MethodGet:
+			a.list(w, r)
+		case http.MethodPost:
+			a.create(w, r)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+	case strings.HasPrefix(r.URL.Path, "/links/"):
+		path := normalizeHost(r.URL.Query().Get("host")) + "/" + strings.TrimPrefix(r.URL.Path, "/links/")
+		switch r.Method {
+		case http.MethodGet:
+			a.get(w, r, path)
+		case http.MethodPut:
+			a.update(w, r, path)
+		case http.MethodDelete:
+			a.delete(w, r, path)
+		default:
+			methodNotAllowed(w, "GET, PUT, DELETE")
+		}
+	case r.URL.Path == "/stats" || strings.HasPrefix(r.URL.Path, "/stats/"):
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		a.stats(w, r, normalizeHost(r.URL.Query().Get("host")), strings.TrimPrefix(r.URL.Path, "/stats"))
+	case r.URL.Path == "/ratelimit":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		a.rateLimits(w)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (a *admin) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if a.token == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(a.token)) == 1
+}
+
+func (a *admin) list(w http.ResponseWriter, r *http.Request) {
+	links, err := a.store.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+func (a *admin) get(w http.ResponseWriter, r *http.Request, path string) {
+	l, err := a.store.Get(r.Context(), path)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (a *admin) create(w http.ResponseWriter, r *http.Request) {
+	var l Link
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	l.Host = normalizeHost(l.Host)
+	l.CreatedAt = time.Now().UTC()
+
+	generated := l.Path == ""
+	if generated {
+		if err := a.generatePath(&l); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	} else {
+		l.Path = cleanPath(l.Path)
+	}
+
+	if status, err := a.check(r.Context(), r.Host, l); err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	// Retry generated codes which happen to be taken already
+	for i := 0; i < codeAttempts; i++ {
+		err := a.store.Create(r.Context(), l)
+		if err == ErrExists && generated {
+			if err := a.generatePath(&l); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			continue
+		}
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, l)
+		return
+	}
+	writeError(w, http.StatusConflict, errors.New("could not generate a free short code"))
+}
+
+func (a *admin) generatePath(l *Link) error {
+	code, err := shortCode(codeLength)
+	if err != nil {
+		return err
+	}
+	l.Path = "/" + code
+	return nil
+}
+
+func (a *admin) update(w http.ResponseWriter, r *http.Request, path string) {
+	var in Link
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	l, err := a.store.Get(r.Context(), path)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	in.Host, in.Path, in.CreatedAt = l.Host, l.Path, l.CreatedAt
+	l = in
+
+	if status, err := a.check(r.Context(), r.Host, l); err != nil {
+		writeError(w, status, err)
+		return
+	}
+	if err := a.store.Update(r.Context(), l); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// check validates l as it would be stored, replacing any link with
+// the same path and host, returning the status to answer with if it
+// isn't valid. The host the API was reached on counts as a short
+// host, as the links are likely served on it as well.
+func (a *admin) check(ctx context.Context, host string, l Link) (int, error) {
+	opts := *a.opts
+	opts.shortHosts = append(opts.shortHosts[:len(opts.shortHosts):len(opts.shortHosts)], host)
+	if reason := opts.checkLink(l); reason != "" {
+		return http.StatusBadRequest, ValidationError{Index: 1, Path: l.Path, Reason: reason}
+	}
+
+	links, err := a.store.List(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	all := []Link{l}
+	for _, other := range links {
+		if other.key() != l.key() {
+			all = append(all, other)
+		}
+	}
+
+	for _, loop := range opts.loops(all) {
+		if loop[0] == 0 {
+			reason := "redirects in a loop: " + loopPath(all, loop)
+			return http.StatusBadRequest, ValidationError{Index: 1, Path: l.Path, Reason: reason}
+		}
+	}
+	return 0, nil
+}
+
+// stats serves the statistics of all links, or only of the one for
+// path on host when given, provided the store records hits
+func (a *admin) stats(w http.ResponseWriter, r *http.Request, host, path string) {
+	hs, ok := a.store.(HitStore)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("the store doesn't record hits"))
+		return
+	}
+
+	if path == "" || path == "/" {
+		stats, err := hs.Stats(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+		return
+	}
+
+	stats, err := hs.LinkStats(r.Context(), host, path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// rateLimits serves the counters of the rate limiters
+func (a *admin) rateLimits(w http.ResponseWriter) {
+	stats := make(map[string]RateLimitStats)
+	for name, l := range a.limiters {
+		stats[name] = l.Stats()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *admin) delete(w http.ResponseWriter, r *http.Request, path string) {
+	if err := a.store.Delete(r.Context(), path); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// shortCode returns a random code of n characters, leaving out the
+// ones easily mistaken for each other
+func shortCode(n int) (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, n)
+	for i := range code {
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[c.Int64()]
+	}
+	return string(code), nil
+}
+
+// cleanPath makes sure path starts with a slash
+func cleanPath(path string) string {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+func statusFor(err error) int {
+	switch err {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrExists:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	e.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,189 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// dayFormat is the layout of the days hits are counted per
+const dayFormat = "2006-01-02"
+
+// Hit is a single redirect of a short link. Hits are recorded for the
+// host and path of the link rather than the path requested, so that
+// the hits of a prefix or pattern are counted together.
+type Hit struct {
+	Host      string    `json:"host,omitempty"`
+	Path      string    `json:"path"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// HitStore records hits and summarises them per link
+type HitStore interface {
+	Record(ctx context.Context, h Hit) error
+	// Stats returns the statistics of every link hit at least once,
+	// ordered by host and path
+	Stats(ctx context.Context) ([]LinkStats, error)
+	// LinkStats returns the statistics of the link with path on host,
+	// without any hits if it wasn't hit yet
+	LinkStats(ctx context.Context, host, path string) (LinkStats, error)
+}
+
+// LinkStats summarises the hits of a single link
+type LinkStats struct {
+	Host       string         `json:"host,omitempty"`
+	Path       string         `json:"path"`
+	Hits       int            `json:"hits"`
+	FirstHit   time.Time      `json:"first_hit"`
+	LastHit    time.Time      `json:"last_hit"`
+	Referrers  map[string]int `json:"referrers"`
+	UserAgents map[string]int `json:"user_agents"`
+	// Daily counts the hits per UTC day, keyed like 2006-01-02
+	Daily map[string]int `json:"daily"`
+}
+
+func (s *LinkStats) add(h Hit) {
+	if s.Referrers == nil {
+		s.Referrers = make(map[string]int)
+		s.UserAgents = make(map[string]int)
+		s.Daily = make(map[string]int)
+	}
+
+	s.Hits++
+	if s.FirstHit.IsZero() || h.Time.Before(s.FirstHit) {
+		s.FirstHit = h.Time
+	}
+	if h.Time.After(s.LastHit) {
+		s.LastHit = h.Time
+	}
+	if h.Referrer != "" {
+		s.Referrers[h.Referrer]++
+	}
+	if h.UserAgent != "" {
+		s.UserAgents[h.UserAgent]++
+	}
+	s.Daily[h.Time.UTC().Format(dayFormat)]++
+}
+
+// key returns the key of the link the statistics are of
+func (s LinkStats) key() string {
+	return s.Host + s.Path
+}
+
+// Track wraps h, recording a hit for every request one of the handlers
+// of this package answers with a redirect. Use it around them to see
+// which links are actually used. Redirects of other handlers, like a
+// fallback, aren't recorded. Failing to record a hit doesn't fail the
+// request.
+func Track(h http.Handler, hs HitStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, sl := withServed(r)
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if !sl.ok || !isRedirect(sw.status) {
+			return
+		}
+
+		err := hs.Record(r.Context(), Hit{
+			Host:      normalizeHost(sl.link.Host),
+			Path:      sl.link.Path,
+			Time:      time.Now().UTC(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	})
+}
+
+// statusWriter remembers the status code and the number of bytes
+// written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// WriteSummary writes the top links by hits, along with the hits of
+// all links per day for the last days days up to now
+func WriteSummary(w io.Writer, stats []LinkStats, top, days int, now time.Time) error {
+	sorted := make([]LinkStats, len(stats))
+	copy(sorted, stats)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Hits > sorted[j].Hits })
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Top links:")
+	fmt.Fprintln(tw, "#\tPATH\tHITS\tLAST HIT\tTOP REFERRER")
+	for i, s := range sorted {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", i+1, s.key(), s.Hits, s.LastHit.Format("2006-01-02 15:04"), topKey(s.Referrers))
+	}
+
+	daily := make(map[string]int)
+	for _, s := range stats {
+		for day, n := range s.Daily {
+			daily[day] += n
+		}
+	}
+	fmt.Fprintln(tw, "\nHits per day:")
+	for i := days - 1; i >= 0; i-- {
+		day := now.UTC().AddDate(0, 0, -i).Format(dayFormat)
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", day, daily[day], strings.Repeat("#", min(daily[day], 50)))
+	}
+
+	return tw.Flush()
+}
+
+// topKey returns the key with the highest count, "-" if there is none
+func topKey(counts map[string]int) string {
+	best, n := "-", 0
+	for k, c := range counts {
+		if c > n || (c == n && k < best) {
+			best, n = k, c
+		}
+	}
+	return best
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,316 @@
+package urlshort
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	linksBucket  = []byte("links")
+	hitsBucket   = []byte("hits")
+	limitsBucket = []byte("limits")
+)
+
+// BoltStore is a LinkStore and HitStore keeping its links in a BoltDB
+// database, so they persist across restarts. Links are stored as JSON
+// keyed by their host and path, hits in a bucket per link. The hits of
+// links with a limit are counted separately, so that the count doesn't
+// depend on hits being recorded.
+//
+// The links with a prefix or pattern are kept in memory as well, so
+// links must only be changed through the store while it is open.
+type BoltStore struct {
+	DB *bolt.DB
+
+	// mu guards routes, the router of the prefixes and patterns, which
+	// is nil until it is needed and again once they changed
+	mu     sync.Mutex
+	routes *router
+}
+
+// OpenBoltStore opens, creating it if needed, the database at path.
+// It gives up after a second when another process has the database
+// open.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, hitsBucket, limitsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{DB: db}, nil
+}
+
+// Close closes the underlying database
+func (s *BoltStore) Close() error {
+	return s.DB.Close()
+}
+
+// Lookup returns the URL path redirects to, which can match a prefix
+// or pattern as well
+func (s *BoltStore) Lookup(ctx context.Context, path string) (string, bool, error) {
+	host, path := splitKey(path)
+	l, params, ok, err := s.match(ctx, host, path)
+	if !ok {
+		return "", false, err
+	}
+	return l.destination(params, ""), true, nil
+}
+
+// match returns the link for path on host. Exact paths are looked up
+// directly, prefixes and patterns in the router of them once no exact
+// path matched.
+func (s *BoltStore) match(ctx context.Context, host, path string) (Link, map[string]string, bool, error) {
+	var patterns *router
+	for _, h := range scopes(host) {
+		l, err := s.Get(ctx, h+path)
+		if err == nil {
+			return l, nil, true, nil
+		}
+		if err != ErrNotFound {
+			return Link{}, nil, false, err
+		}
+
+		if patterns == nil {
+			if patterns, err = s.patterns(); err != nil {
+				return Link{}, nil, false, err
+			}
+		}
+		if t, ok := patterns.scopes[h]; ok {
+			if l, params, ok := t.match(path); ok {
+				return l, params, true, nil
+			}
+		}
+	}
+	return Link{}, nil, false, nil
+}
+
+// patterns returns a router of all links with a prefix or pattern as
+// their path, which is only built again after they changed
+func (s *BoltStore) patterns() (*router, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.routes != nil {
+		return s.routes, nil
+	}
+
+	var links []Link
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			if !isPattern(string(k)) {
+				return nil
+			}
+			var l Link
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			links = append(links, l)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.routes = newRouter(links)
+	return s.routes, nil
+}
+
+// changing is called before the link with key is changed, returning
+// the function to call once it was. Changes to prefixes and patterns
+// hold off building the router until they are done and have it built
+// again afterwards.
+func (s *BoltStore) changing(key string) (done func()) {
+	if !isPattern(key) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return func() {
+		s.routes = nil
+		s.mu.Unlock()
+	}
+}
+
+// Get returns the link for path
+func (s *BoltStore) Get(ctx context.Context, path string) (Link, error) {
+	var l Link
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(linksBucket).Get([]byte(normalizeKey(path)))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &l)
+	})
+	return l, err
+}
+
+// List returns all links ordered by host and path
+func (s *BoltStore) List(ctx context.Context) ([]Link, error) {
+	links := []Link{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			var l Link
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			links = append(links, l)
+			return nil
+		})
+	})
+	return links, err
+}
+
+// Create adds a new link
+func (s *BoltStore) Create(ctx context.Context, l Link) error {
+	return s.put(l, false)
+}
+
+// Update replaces an existing link
+func (s *BoltStore) Update(ctx context.Context, l Link) error {
+	return s.put(l, true)
+}
+
+// put stores l, which must exist already when replace is set and
+// must not exist otherwise
+func (s *BoltStore) put(l Link, replace bool) error {
+	l.Host = normalizeHost(l.Host)
+	buf, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	defer s.changing(l.key())()
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		exists := b.Get([]byte(l.key())) != nil
+		switch {
+		case replace && !exists:
+			return ErrNotFound
+		case !replace && exists:
+			return ErrExists
+		}
+		return b.Put([]byte(l.key()), buf)
+	})
+}
+
+// Delete removes the link for path, along with the count of its hits
+// towards its limit
+func (s *BoltStore) Delete(ctx context.Context, path string) error {
+	key := []byte(normalizeKey(path))
+	defer s.changing(string(key))()
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		if b.Get(key) == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(limitsBucket).Delete(key); err != nil {
+			return err
+		}
+		return b.Delete(key)
+	})
+}
+
+// countHit counts a hit of the link with key, reporting false once it
+// was hit max times
+func (s *BoltStore) countHit(ctx context.Context, key string, max int) (bool, error) {
+	ok := false
+	err := s.DB.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(limitsBucket)
+		var n uint64
+		if v := b.Get([]byte(key)); v != nil {
+			n = binary.BigEndian.Uint64(v)
+		}
+		if ok = n < uint64(max); !ok {
+			return nil
+		}
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, n+1)
+		return b.Put([]byte(key), buf)
+	})
+	return ok, err
+}
+
+// Record stores a hit of a link. Hits of concurrent requests are
+// written in a single transaction.
+func (s *BoltStore) Record(ctx context.Context, h Hit) error {
+	h.Host = normalizeHost(h.Host)
+	buf, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(hitsBucket).CreateBucketIfNotExists([]byte(h.Host + h.Path))
+		if err != nil {
+			return err
+		}
+
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return b.Put(key, buf)
+	})
+}
+
+// Stats returns the statistics of every link hit at least once
+func (s *BoltStore) Stats(ctx context.Context) ([]LinkStats, error) {
+	stats := []LinkStats{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(hitsBucket).ForEach(func(key, _ []byte) error {
+			ls, err := linkStats(tx, key)
+			stats = append(stats, ls)
+			return err
+		})
+	})
+	return stats, err
+}
+
+// LinkStats returns the statistics of the link with path on host
+func (s *BoltStore) LinkStats(ctx context.Context, host, path string) (LinkStats, error) {
+	var ls LinkStats
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		ls, err = linkStats(tx, []byte(normalizeHost(host)+path))
+		return err
+	})
+	return ls, err
+}
+
+// linkStats sums up the hits recorded under key
+func linkStats(tx *bolt.Tx, key []byte) (LinkStats, error) {
+	var ls LinkStats
+	ls.Host, ls.Path = splitKey(string(key))
+	b := tx.Bucket(hitsBucket).Bucket(key)
+	if b == nil {
+		return ls, nil
+	}
+	err := b.ForEach(func(_, v []byte) error {
+		var h Hit
+		if err := json.Unmarshal(v, &h); err != nil {
+			return err
+		}
+		ls.add(h)
+		return nil
+	})
+	return ls, err
+}
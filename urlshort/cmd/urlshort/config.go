@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/prmsrswt/gophercises/internal/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// config is everything the server can be configured with, either in
+// a YAML file or with flags. Flags take precedence over the file.
+type config struct {
+	Addr string `yaml:"addr"`
+	// Sources are YAML, JSON or CSV files of links, consulted in order
+	Sources []string `yaml:"sources"`
+	// Watch is how often the sources are checked for changes
+	Watch duration `yaml:"watch"`
+	// Store is where links managed through the admin API are kept:
+	// none, memory or bolt
+	Store string `yaml:"store"`
+	DB    string `yaml:"db"`
+	// AdminToken enables the admin API below /admin/
+	AdminToken string `yaml:"admin_token"`
+	// Proxy is a URL to forward requests for unknown paths to,
+	// otherwise they are answered with NotFound, an HTML page
+	Proxy    string `yaml:"proxy"`
+	NotFound string `yaml:"not_found"`
+	// AllowedHosts and DeniedHosts restrict the hosts links may
+	// redirect to, ShortHosts are the hosts the server is reachable on,
+	// to detect links redirecting in a loop. ShortHosts default to the
+	// hosts of Addr, see listenHosts.
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	DeniedHosts  []string `yaml:"denied_hosts"`
+	ShortHosts   []string `yaml:"short_hosts"`
+	// RateLimit is how many requests per second every client IP may
+	// make, in bursts of up to RateBurst, 0 for no limit. The admin
+	// API has its own limit.
+	RateLimit      float64 `yaml:"rate_limit"`
+	RateBurst      int     `yaml:"rate_burst"`
+	AdminRateLimit float64 `yaml:"admin_rate_limit"`
+	AdminRateBurst int     `yaml:"admin_rate_burst"`
+	// TrustProxy takes the IPs of clients from the X-Forwarded-For
+	// header, for servers behind a proxy
+	TrustProxy bool `yaml:"trust_proxy"`
+	// Metrics serves metrics at /metrics in the Prometheus text format
+	Metrics bool `yaml:"metrics"`
+	// AccessLog is a file to log requests to, "-" for stdout
+	AccessLog       string   `yaml:"access_log"`
+	ShutdownTimeout duration `yaml:"shutdown_timeout"`
+}
+
+// duration is a time.Duration read from strings like "2s" in YAML
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func defaultConfig() config {
+	return config{
+		Addr:            ":8080",
+		Watch:           duration{2 * time.Second},
+		Store:           "bolt",
+		DB:              "urlshort.db",
+		RateLimit:       10,
+		RateBurst:       20,
+		AdminRateLimit:  1,
+		AdminRateBurst:  10,
+		Metrics:         true,
+		ShutdownTimeout: duration{10 * time.Second},
+	}
+}
+
+// parseConfig reads the config file given with -config, if any, and
+// applies the flags on top of it
+func parseConfig(args []string) (config, error) {
+	cfg := defaultConfig()
+	var path, sources, allowed, denied, short string
+
+	fs := flag.NewFlagSet("urlshort", flag.ExitOnError)
+	fs.StringVar(&path, "config", "", "a YAML config file, flags take precedence over it")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "the address to listen on")
+	fs.StringVar(&sources, "sources", "", "comma separated YAML, JSON or CSV files of links, consulted in order")
+	fs.DurationVar(&cfg.Watch.Duration, "watch", cfg.Watch.Duration, "how often to check the sources for changes")
+	fs.StringVar(&cfg.Store, "store", cfg.Store, "where to keep links managed through the admin API: none, memory or bolt")
+	fs.StringVar(&cfg.DB, "db", cfg.DB, "the database of the bolt store")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "enable the admin API below /admin/ with this bearer token")
+	fs.StringVar(&cfg.Proxy, "proxy", cfg.Proxy, "proxy requests for unknown paths to this URL")
+	fs.StringVar(&cfg.NotFound, "not-found", cfg.NotFound, "an HTML page to answer requests for unknown paths with")
+	fs.StringVar(&allowed, "allow-hosts", "", "comma separated hosts links may redirect to, *.example.com for subdomains")
+	fs.StringVar(&denied, "deny-hosts", "", "comma separated hosts links may not redirect to")
+	fs.StringVar(&short, "short-hosts", "", "comma separated hosts the server is reachable on, to detect redirect loops (default: the hosts of the listen address)")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "requests per second every client may make, 0 for no limit")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", cfg.RateBurst, "requests every client may make at once")
+	fs.Float64Var(&cfg.AdminRateLimit, "admin-rate-limit", cfg.AdminRateLimit, "requests per second every client may make to the admin API, 0 for no limit")
+	fs.IntVar(&cfg.AdminRateBurst, "admin-rate-burst", cfg.AdminRateBurst, "requests every client may make at once to the admin API")
+	fs.BoolVar(&cfg.TrustProxy, "trust-proxy", cfg.TrustProxy, "take the IPs of clients from the X-Forwarded-For header")
+	fs.BoolVar(&cfg.Metrics, "metrics", cfg.Metrics, "serve metrics at /metrics in the Prometheus text format")
+	fs.StringVar(&cfg.AccessLog, "access-log", cfg.AccessLog, "log requests to this file, - for stdout")
+	fs.DurationVar(&cfg.ShutdownTimeout.Duration, "shutdown-timeout", cfg.ShutdownTimeout.Duration, "how long to wait for requests to finish when shutting down")
+	fs.Parse(args)
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %v", path, err)
+		}
+		// Parse again for the flags to override the file
+		fs.Parse(args)
+	}
+	for _, list := range []struct {
+		flag string
+		dst  *[]string
+	}{
+		{sources, &cfg.Sources},
+		{allowed, &cfg.AllowedHosts},
+		{denied, &cfg.DeniedHosts},
+		{short, &cfg.ShortHosts},
+	} {
+		if list.flag != "" {
+			*list.dst = cli.SplitList(list.flag)
+		}
+	}
+
+	if cfg.Proxy != "" && cfg.NotFound != "" {
+		return cfg, fmt.Errorf("set either a proxy or a not found page")
+	}
+	if len(cfg.ShortHosts) == 0 {
+		cfg.ShortHosts = listenHosts(cfg.Addr)
+	}
+	return cfg, nil
+}
+
+// listenHosts returns the hosts the server listening on addr can be
+// reached on: the host of addr, or when it listens on all addresses,
+// localhost, the hostname of the machine and the addresses of all its
+// interfaces
+func listenHosts(addr string) []string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if host != "" && (ip == nil || !ip.IsUnspecified()) {
+		if ip != nil && ip.IsLoopback() {
+			return []string{host, "localhost"}
+		}
+		return []string{host}
+	}
+
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			hosts = append(hosts, ipnet.IP.String())
+		}
+	}
+	return hosts
+}
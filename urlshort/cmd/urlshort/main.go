@@ -0,0 +1,191 @@
+// Command urlshort serves short links from YAML, JSON and CSV files
+// and from a store managed through its admin API.
+//
+//	urlshort -sources links.yaml,more.csv -admin-token secret
+//	urlshort -config urlshort.yaml
+//	urlshort stats -db urlshort.db
+//
+// Appending a + to a short link, like /github+, shows where it leads
+// instead of redirecting, and /qr/github is a QR code of it. Metrics
+// are served at /metrics in the Prometheus text format.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/prmsrswt/gophercises/internal/cli"
+	"github.com/prmsrswt/gophercises/urlshort"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		if err := stats(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := parseConfig(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := run(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run serves until SIGINT or SIGTERM, then waits for the requests in
+// flight to finish
+func run(cfg config) error {
+	ctx, cancel := cli.Interruptible(context.Background())
+	defer cancel()
+
+	limiter := urlshort.NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	adminLimiter := urlshort.NewRateLimiter(cfg.AdminRateLimit, cfg.AdminRateBurst)
+	limiter.TrustProxy, adminLimiter.TrustProxy = cfg.TrustProxy, cfg.TrustProxy
+	metrics := urlshort.NewMetrics(
+		urlshort.WithMetricsRateLimiter("redirects", limiter),
+		urlshort.WithMetricsRateLimiter("admin", adminLimiter),
+	)
+
+	handler, err := fallback(cfg)
+	if err != nil {
+		return err
+	}
+	handler = metrics.Fallback(handler)
+
+	opts := []urlshort.Option{
+		urlshort.WithAllowedHosts(cfg.AllowedHosts...),
+		urlshort.WithDeniedHosts(cfg.DeniedHosts...),
+		urlshort.WithShortHosts(cfg.ShortHosts...),
+	}
+
+	// The first source knowing a path wins, so chain them backwards
+	var all urlshort.MultiStore
+	for i := len(cfg.Sources) - 1; i >= 0; i-- {
+		fs, err := urlshort.NewFileStore(cfg.Sources[i], opts...)
+		if err != nil {
+			return err
+		}
+		handler = urlshort.StoreHandler(metrics.Store(cfg.Sources[i], fs), handler)
+		go fs.Watch(ctx, cfg.Watch.Duration)
+		all = append(urlshort.MultiStore{fs}, all...)
+	}
+
+	var store urlshort.LinkStore
+	var hits urlshort.HitStore
+	switch cfg.Store {
+	case "none", "":
+	case "memory":
+		store = urlshort.NewMemoryStore(nil)
+		handler = urlshort.StoreHandler(metrics.Store(cfg.Store, store), handler)
+	case "bolt":
+		bs, err := urlshort.OpenBoltStore(cfg.DB)
+		if err != nil {
+			return err
+		}
+		defer bs.Close()
+		store, hits = bs, bs
+		handler = urlshort.Track(urlshort.StoreHandler(metrics.Store(cfg.Store, bs), handler), bs)
+	default:
+		return fmt.Errorf("unknown store %q, use none, memory or bolt", cfg.Store)
+	}
+	if store != nil {
+		all = append(urlshort.MultiStore{store}, all...)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", limiter.Limit(metrics.Count(urlshort.Preview(handler, all, hits))))
+	if cfg.Metrics {
+		mux.Handle("/metrics", metrics)
+	}
+	if cfg.AdminToken != "" {
+		if store == nil {
+			return fmt.Errorf("the admin API needs a store")
+		}
+		admin := urlshort.AdminHandler(store, cfg.AdminToken,
+			urlshort.WithValidation(opts...),
+			urlshort.WithAdminRateLimiter("redirects", limiter),
+			urlshort.WithAdminRateLimiter("admin", adminLimiter),
+		)
+		mux.Handle("/admin/", http.StripPrefix("/admin", adminLimiter.Limit(admin)))
+	}
+
+	var h http.Handler = mux
+	if cfg.AccessLog != "" {
+		w, err := accessLog(cfg.AccessLog)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		h = urlshort.AccessLog(h, w)
+	}
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: h}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	log.Printf("Serving short links on %s", cfg.Addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down...")
+	sctx, scancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer scancel()
+	return srv.Shutdown(sctx)
+}
+
+// fallback returns the handler for requests of unknown paths: a
+// proxy, a not found page or a plain 404
+func fallback(cfg config) (http.Handler, error) {
+	switch {
+	case cfg.Proxy != "":
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %v", err)
+		}
+		return httputil.NewSingleHostReverseProxy(u), nil
+	case cfg.NotFound != "":
+		page, err := ioutil.ReadFile(cfg.NotFound)
+		if err != nil {
+			return nil, err
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(page)
+		}), nil
+	}
+	return http.NotFoundHandler(), nil
+}
+
+// accessLog opens the file to log requests to
+func accessLog(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
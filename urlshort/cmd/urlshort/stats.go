@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prmsrswt/gophercises/urlshort"
+)
+
+// stats prints the top links and the hits per day, read from the
+// database or from the admin API of a running server, as the server
+// keeps the database locked
+func stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	db := fs.String("db", "urlshort.db", "the database to read the hits from")
+	server := fs.String("server", "", "read the hits from the admin API of the server at this URL instead")
+	token := fs.String("admin-token", "", "the token of the admin API (default: $URLSHORT_TOKEN)")
+	top := fs.Int("top", 10, "the number of top links to show")
+	days := fs.Int("days", 7, "the number of days to show the hits of")
+	fs.Parse(args)
+	if *token == "" {
+		*token = os.Getenv("URLSHORT_TOKEN")
+	}
+
+	var linkStats []urlshort.LinkStats
+	if *server != "" {
+		req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(*server, "/")+"/admin/stats", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+*token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetching stats: %s", resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&linkStats); err != nil {
+			return err
+		}
+	} else {
+		store, err := urlshort.OpenBoltStore(*db)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		linkStats, err = store.Stats(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	return urlshort.WriteSummary(os.Stdout, linkStats, *top, *days, time.Now())
+}
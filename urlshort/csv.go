@@ -0,0 +1,105 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns are the columns of a CSV file with a header, matching
+// the fields of Link
+var csvColumns = []string{"path", "url", "host", "status", "not_before", "expires_at", "max_hits", "forward_query"}
+
+// parseCSV parses links from CSV. Files without a header have the
+// path and URL in the first two columns. A header naming the columns
+// allows using all the fields of Link, like:
+//
+//	path,url,status,expires_at
+//	/launch,https://www.some-url.com/launch,301,2020-07-01T00:00:00Z
+//
+// Times are expected in RFC 3339 format.
+func parseCSV(csvBytes []byte) ([]Link, error) {
+	r := csv.NewReader(bytes.NewReader(csvBytes))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := []string{"path", "url"}
+	if isCSVHeader(rows[0]) {
+		columns = rows[0]
+		rows = rows[1:]
+	}
+
+	links := make([]Link, 0, len(rows))
+	for i, row := range rows {
+		var l Link
+		for j, v := range row {
+			if j >= len(columns) {
+				break
+			}
+			if err := l.set(strings.ToLower(strings.TrimSpace(columns[j])), strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("record %d: %v", i+1, err)
+			}
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+func isCSVHeader(row []string) bool {
+	for _, v := range row {
+		if strings.EqualFold(strings.TrimSpace(v), "path") {
+			return true
+		}
+	}
+	return false
+}
+
+// set sets the field of the link for column to v
+func (l *Link) set(column, v string) error {
+	if v == "" {
+		return nil
+	}
+
+	var err error
+	switch column {
+	case "path":
+		l.Path = v
+	case "url":
+		l.URL = v
+	case "host":
+		l.Host = v
+	case "status":
+		l.Status, err = strconv.Atoi(v)
+	case "max_hits":
+		l.MaxHits, err = strconv.Atoi(v)
+	case "forward_query":
+		l.ForwardQuery, err = strconv.ParseBool(v)
+	case "not_before", "expires_at":
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, v); err == nil {
+			if column == "not_before" {
+				l.NotBefore = &t
+			} else {
+				l.ExpiresAt = &t
+			}
+		}
+	default:
+		return fmt.Errorf("unknown column %q, expected one of %s", column, strings.Join(csvColumns, ", "))
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", column, v)
+	}
+	return nil
+}
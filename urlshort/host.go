@@ -0,0 +1,92 @@
+package urlshort
+
+import (
+	"net"
+	"strings"
+)
+
+// Links can be scoped to a host, only redirecting requests for that
+// host, so that several short domains can be served at once. Links
+// without a host are in the default scope, used for requests to any
+// host which has no link of its own for the path.
+//
+// Stores key links by their host followed by their path, like
+// "go.team-a/docs", or just their path for links in the default
+// scope. MapHandler takes keys of the same form.
+
+// key returns the key of the link in a store
+func (l Link) key() string {
+	return normalizeHost(l.Host) + l.Path
+}
+
+// splitKey splits a key into the host and path of a link. Keys which
+// don't start with a slash start with a host.
+func splitKey(key string) (host, path string) {
+	if strings.HasPrefix(key, "/") {
+		return "", key
+	}
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return normalizeHost(key), "/"
+	}
+	return normalizeHost(key[:i]), key[i:]
+}
+
+// normalizeKey normalizes the host of key, if it has one
+func normalizeKey(key string) string {
+	host, path := splitKey(key)
+	return host + path
+}
+
+// normalizeHost lower cases host and drops any port and trailing dot,
+// so that it can be compared with the Host header of requests
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// scopes returns the scopes to look in for a request to host, in
+// order
+func scopes(host string) []string {
+	if host == "" {
+		return []string{""}
+	}
+	return []string{host, ""}
+}
+
+// router finds the link for a request among the links scoped to its
+// host first, and the links in the default scope second
+type router struct {
+	scopes map[string]*table
+}
+
+func newRouter(links []Link) *router {
+	byHost := make(map[string][]Link)
+	for _, l := range links {
+		h := normalizeHost(l.Host)
+		byHost[h] = append(byHost[h], l)
+	}
+
+	rt := &router{scopes: make(map[string]*table)}
+	for h, links := range byHost {
+		rt.scopes[h] = newTable(links)
+	}
+	return rt
+}
+
+// match returns the link for path on host along with the parameters
+// the path matched
+func (rt *router) match(host, path string) (Link, map[string]string, bool) {
+	for _, h := range scopes(normalizeHost(host)) {
+		t, ok := rt.scopes[h]
+		if !ok {
+			continue
+		}
+		if l, params, ok := t.match(path); ok {
+			return l, params, true
+		}
+	}
+	return Link{}, nil, false
+}
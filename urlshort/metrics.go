@@ -0,0 +1,290 @@
+package urlshort
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMetricLinks is how many links redirects are counted for one by
+// one, the redirects of any further links are counted as "other" for
+// the number of series to stay bounded
+const maxMetricLinks = 1000
+
+// lookupBuckets are the upper bounds in seconds of the buckets of the
+// lookup latency histograms
+var lookupBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Metrics counts what the handlers and stores of this package do and
+// serves the counts in the Prometheus text format, to be scraped at
+// something like /metrics. It counts:
+//
+//	urlshort_redirects_total           redirects by link and status, see Count
+//	urlshort_gone_total                410 Gone answers by link, see Count
+//	urlshort_fallbacks_total           requests passed on to the fallback, see Fallback
+//	urlshort_lookup_duration_seconds   lookups per store, see Store
+//	urlshort_reloads_total             reloads of file stores, see Store
+//	urlshort_rate_limit_*              counters of the rate limiters given
+//	                                   with WithMetricsRateLimiter
+type Metrics struct {
+	limiters map[string]*RateLimiter
+
+	mu        sync.Mutex
+	redirects map[[2]string]uint64
+	gone      map[string]uint64
+	links     map[string]bool
+	fallbacks uint64
+	lookups   map[string]*histogram
+	reloads   map[[2]string]uint64
+}
+
+type histogram struct {
+	// counts are the observations per bucket, the last one counting
+	// those above all bounds
+	counts []uint64
+	sum    float64
+}
+
+// NewMetrics returns a Metrics with all counts at zero
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{
+		limiters:  make(map[string]*RateLimiter),
+		redirects: make(map[[2]string]uint64),
+		gone:      make(map[string]uint64),
+		links:     make(map[string]bool),
+		lookups:   make(map[string]*histogram),
+		reloads:   make(map[[2]string]uint64),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Count wraps h, counting the requests the handlers of this package
+// answer with a redirect by the link, keyed like "go.team-a/docs", and
+// status, and those answered with 410 Gone for an expired or used up
+// link by the link. Redirects of other handlers, like a fallback,
+// aren't counted.
+func (m *Metrics) Count(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, sl := withServed(r)
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if !sl.ok || !isRedirect(sw.status) && sw.status != http.StatusGone {
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		key := sl.link.key()
+		if !m.links[key] {
+			if len(m.links) >= maxMetricLinks {
+				key = "other"
+			} else {
+				m.links[key] = true
+			}
+		}
+		if sw.status == http.StatusGone {
+			m.gone[key]++
+			return
+		}
+		m.redirects[[2]string{key, strconv.Itoa(sw.status)}]++
+	})
+}
+
+// Fallback wraps the fallback handler of the handlers of this
+// package, counting the requests for unknown paths
+func (m *Metrics) Fallback(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.fallbacks++
+		m.mu.Unlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Store wraps s, observing how long its lookups take under name. The
+// store returned finds the same links as s with StoreHandler, and is
+// meant to be given to it only, so that only the lookups of redirects
+// are observed. When s
+// is a FileStore its reloads are counted as well, by file and result.
+func (m *Metrics) Store(name string, s Store) Store {
+	if fs, ok := s.(*FileStore); ok {
+		fs.mu.Lock()
+		fs.metrics = m
+		fs.mu.Unlock()
+	}
+	return &timedStore{Store: s, name: name, m: m}
+}
+
+type timedStore struct {
+	Store
+	name string
+	m    *Metrics
+}
+
+func (s *timedStore) Lookup(ctx context.Context, path string) (string, bool, error) {
+	defer s.m.observe(s.name, time.Now())
+	return s.Store.Lookup(ctx, path)
+}
+
+func (s *timedStore) match(ctx context.Context, host, path string) (Link, map[string]string, bool, error) {
+	defer s.m.observe(s.name, time.Now())
+	return lookupLink(ctx, s.Store, host, path)
+}
+
+// observe records a lookup of the store with name which started at
+// start
+func (m *Metrics) observe(name string, start time.Time) {
+	v := time.Since(start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.lookups[name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(lookupBuckets)+1)}
+		m.lookups[name] = h
+	}
+	i := sort.SearchFloat64s(lookupBuckets, v)
+	h.counts[i]++
+	h.sum += v
+}
+
+// reloaded counts a reload of source, which failed with err if not nil
+func (m *Metrics) reloaded(source string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads[[2]string{source, result}]++
+}
+
+// ServeHTTP writes all the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family(buf, "urlshort_redirects_total", "counter", "Redirects of short links by link and status.")
+	for _, k := range sortedKeys(m.redirects) {
+		sample(buf, "urlshort_redirects_total", labels("link", k[0], "status", k[1]), float64(m.redirects[k]))
+	}
+
+	family(buf, "urlshort_gone_total", "counter", "Requests for expired or used up links answered with 410 Gone, by link.")
+	var links []string
+	for link := range m.gone {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+	for _, link := range links {
+		sample(buf, "urlshort_gone_total", labels("link", link), float64(m.gone[link]))
+	}
+
+	family(buf, "urlshort_fallbacks_total", "counter", "Requests for unknown paths passed on to the fallback.")
+	sample(buf, "urlshort_fallbacks_total", "", float64(m.fallbacks))
+
+	family(buf, "urlshort_lookup_duration_seconds", "histogram", "How long looking up links in a store takes.")
+	var names []string
+	for name := range m.lookups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := m.lookups[name]
+		var n uint64
+		for i, bound := range lookupBuckets {
+			n += h.counts[i]
+			sample(buf, "urlshort_lookup_duration_seconds_bucket", labels("store", name, "le", formatFloat(bound)), float64(n))
+		}
+		n += h.counts[len(lookupBuckets)]
+		sample(buf, "urlshort_lookup_duration_seconds_bucket", labels("store", name, "le", "+Inf"), float64(n))
+		sample(buf, "urlshort_lookup_duration_seconds_sum", labels("store", name), h.sum)
+		sample(buf, "urlshort_lookup_duration_seconds_count", labels("store", name), float64(n))
+	}
+
+	family(buf, "urlshort_reloads_total", "counter", "Reloads of file stores by source and result.")
+	for _, k := range sortedKeys(m.reloads) {
+		sample(buf, "urlshort_reloads_total", labels("source", k[0], "result", k[1]), float64(m.reloads[k]))
+	}
+
+	if len(m.limiters) == 0 {
+		return
+	}
+	names = names[:0]
+	stats := make(map[string]RateLimitStats)
+	for name, l := range m.limiters {
+		names = append(names, name)
+		stats[name] = l.Stats()
+	}
+	sort.Strings(names)
+	family(buf, "urlshort_rate_limit_allowed_total", "counter", "Requests let through by a rate limiter.")
+	for _, name := range names {
+		sample(buf, "urlshort_rate_limit_allowed_total", labels("limiter", name), float64(stats[name].Allowed))
+	}
+	family(buf, "urlshort_rate_limit_limited_total", "counter", "Requests refused by a rate limiter.")
+	for _, name := range names {
+		sample(buf, "urlshort_rate_limit_limited_total", labels("limiter", name), float64(stats[name].Limited))
+	}
+	family(buf, "urlshort_rate_limit_clients", "gauge", "Clients currently tracked by a rate limiter.")
+	for _, name := range names {
+		sample(buf, "urlshort_rate_limit_clients", labels("limiter", name), float64(stats[name].Clients))
+	}
+}
+
+func family(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sample(buf *bytes.Buffer, name, labels string, v float64) {
+	fmt.Fprintf(buf, "%s%s %s\n", name, labels, formatFloat(v))
+}
+
+// labels formats pairs of label names and values like {a="1",b="2"}
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(pairs[i] + `="` + labelEscaper.Replace(pairs[i+1]) + `"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
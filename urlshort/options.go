@@ -0,0 +1,76 @@
+package urlshort
+
+// Option configures the validation of links by the handlers and stores
+// of this package
+type Option func(*options)
+
+type options struct {
+	allowed    []string
+	denied     []string
+	shortHosts []string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithAllowedHosts is an option to only allow links to the given
+// hosts. A host like "*.example.com" allows all subdomains. Links
+// whose URL uses parameters in its host are refused.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(o *options) {
+		o.allowed = append(o.allowed, hosts...)
+	}
+}
+
+// WithDeniedHosts is an option to refuse links to the given hosts. A
+// host like "*.example.com" denies all subdomains. Links whose URL
+// uses parameters in its host are refused as well.
+func WithDeniedHosts(hosts ...string) Option {
+	return func(o *options) {
+		o.denied = append(o.denied, hosts...)
+	}
+}
+
+// WithShortHosts is an option naming the hosts the short links are
+// served on, to detect links redirecting to each other in a loop.
+// The hosts links are scoped to are always taken into account.
+func WithShortHosts(hosts ...string) Option {
+	return func(o *options) {
+		o.shortHosts = append(o.shortHosts, hosts...)
+	}
+}
+
+// AdminOption configures the admin API
+type AdminOption func(*admin)
+
+// WithValidation is an option for the admin API to validate links
+// with opts
+func WithValidation(opts ...Option) AdminOption {
+	return func(a *admin) {
+		a.opts = newOptions(opts)
+	}
+}
+
+// WithAdminRateLimiter is an option for the admin API to serve the
+// counters of the rate limiter l under name
+func WithAdminRateLimiter(name string, l *RateLimiter) AdminOption {
+	return func(a *admin) {
+		a.limiters[name] = l
+	}
+}
+
+// MetricsOption configures a Metrics
+type MetricsOption func(*Metrics)
+
+// WithMetricsRateLimiter is an option for Metrics to serve the
+// counters of the rate limiter l under name
+func WithMetricsRateLimiter(name string, l *RateLimiter) MetricsOption {
+	return func(m *Metrics) {
+		m.limiters[name] = l
+	}
+}
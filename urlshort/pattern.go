@@ -0,0 +1,254 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Paths of links can be patterns rather than exact paths. A path
+// ending in "/*" is a prefix, matching any path below it, with the
+// rest of the path available to the URL as {rest}. Segments of the
+// form {name} match any single segment, available to the URL as
+// {name}, and segments of just "*" match any single segment. So with
+//
+//     - path: /gh/*
+//       url: https://github.com/{rest}
+//     - path: /u/{user}/*
+//       url: https://github.com/{user}?tab={rest}
+//
+// "/gh/golang/go" redirects to "https://github.com/golang/go" and
+// "/u/golang/repositories" to "https://github.com/golang?tab=repositories".
+//
+// Exact paths take precedence over prefixes, with longer prefixes
+// winning over shorter ones, and prefixes over other patterns, with
+// the pattern having the most literal segments winning.
+
+const restParam = "rest"
+
+// rule is a link with a pattern as its path
+type rule struct {
+	link     Link
+	segments []string
+	// rest is set for patterns ending in "/*"
+	rest     bool
+	literals int
+}
+
+func newRule(l Link) rule {
+	r := rule{link: l, segments: splitPath(l.Path)}
+	if n := len(r.segments); n > 0 && r.segments[n-1] == "*" {
+		r.rest = true
+		r.segments = r.segments[:n-1]
+	}
+	for _, s := range r.segments {
+		if !isWildcard(s) {
+			r.literals++
+		}
+	}
+	return r
+}
+
+// exact tells whether the rule matches a single path only
+func (r rule) exact() bool {
+	return !r.rest && r.literals == len(r.segments)
+}
+
+// prefix tells whether the rule matches all paths below a single path
+func (r rule) prefix() bool {
+	return r.rest && r.literals == len(r.segments)
+}
+
+// match returns the parameters of path if it matches the rule
+func (r rule) match(path string) (map[string]string, bool) {
+	segs := splitPath(path)
+	if r.rest && len(segs) <= len(r.segments) || !r.rest && len(segs) != len(r.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, s := range r.segments {
+		switch {
+		case s == "*":
+		case isParam(s):
+			params[s[1:len(s)-1]] = segs[i]
+		case s != segs[i]:
+			return nil, false
+		}
+	}
+	if r.rest {
+		params[restParam] = strings.Join(segs[len(r.segments):], "/")
+	}
+	return params, true
+}
+
+// params returns the names of the parameters the rule defines
+func (r rule) params() []string {
+	var names []string
+	for _, s := range r.segments {
+		if isParam(s) {
+			names = append(names, s[1:len(s)-1])
+		}
+	}
+	if r.rest {
+		names = append(names, restParam)
+	}
+	return names
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func isParam(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func isWildcard(segment string) bool {
+	return segment == "*" || isParam(segment)
+}
+
+// isPattern tells whether path is anything but an exact path
+func isPattern(path string) bool {
+	for _, s := range splitPath(path) {
+		if isWildcard(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// table finds the link for a path among exact paths, prefixes and
+// patterns, in that order
+type table struct {
+	exact    map[string]Link
+	prefixes []rule
+	patterns []rule
+}
+
+// newTable builds a table of links. Later links take precedence over
+// earlier ones with the same path.
+func newTable(links []Link) *table {
+	t := &table{exact: make(map[string]Link)}
+	rules := make(map[string]rule)
+	for _, l := range links {
+		r := newRule(l)
+		if r.exact() {
+			t.exact[l.Path] = l
+		} else {
+			rules[l.Path] = r
+		}
+	}
+
+	for _, r := range rules {
+		if r.prefix() {
+			t.prefixes = append(t.prefixes, r)
+		} else {
+			t.patterns = append(t.patterns, r)
+		}
+	}
+
+	sort.Slice(t.prefixes, func(i, j int) bool {
+		a, b := t.prefixes[i], t.prefixes[j]
+		if len(a.segments) != len(b.segments) {
+			return len(a.segments) > len(b.segments)
+		}
+		return a.link.Path < b.link.Path
+	})
+	sort.Slice(t.patterns, func(i, j int) bool {
+		a, b := t.patterns[i], t.patterns[j]
+		switch {
+		case a.literals != b.literals:
+			return a.literals > b.literals
+		case len(a.segments) != len(b.segments):
+			return len(a.segments) > len(b.segments)
+		case a.rest != b.rest:
+			return !a.rest
+		}
+		return a.link.Path < b.link.Path
+	})
+	return t
+}
+
+// match returns the link for path along with the parameters the path
+// matched
+func (t *table) match(path string) (Link, map[string]string, bool) {
+	if l, ok := t.exact[path]; ok {
+		return l, nil, true
+	}
+	for _, rules := range [][]rule{t.prefixes, t.patterns} {
+		for _, r := range rules {
+			if params, ok := r.match(path); ok {
+				return r.link, params, true
+			}
+		}
+	}
+	return Link{}, nil, false
+}
+
+// destination returns the URL to redirect a request for l to, with
+// the parameters filled in and the query of the request added if
+// the link forwards it
+func (l Link) destination(params map[string]string, query string) string {
+	dest := l.URL
+	if len(params) > 0 {
+		var oldnew []string
+		for name, v := range params {
+			oldnew = append(oldnew, "{"+name+"}", escapePath(v))
+		}
+		dest = strings.NewReplacer(oldnew...).Replace(dest)
+	}
+
+	if !l.ForwardQuery || query == "" {
+		return dest
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + query
+	} else {
+		u.RawQuery = query
+	}
+	return u.String()
+}
+
+// escapePath escapes every segment of path on its own, keeping the
+// slashes between them
+func escapePath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// checkPattern reports parameters used twice in the path of l, or
+// used by its URL without being defined by the path
+func checkPattern(l Link) error {
+	defined := make(map[string]bool)
+	for _, name := range newRule(l).params() {
+		if defined[name] {
+			return fmt.Errorf("parameter {%s} is defined twice", name)
+		}
+		defined[name] = true
+	}
+
+	rest := l.URL
+	for {
+		i := strings.Index(rest, "{")
+		if i < 0 {
+			return nil
+		}
+		j := strings.Index(rest[i:], "}")
+		if j < 0 {
+			return nil
+		}
+		if name := rest[i+1 : i+j]; !defined[name] {
+			return fmt.Errorf("url uses {%s}, which the path doesn't define", name)
+		}
+		rest = rest[i+j+1:]
+	}
+}
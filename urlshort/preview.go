@@ -0,0 +1,168 @@
+package urlshort
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image/png"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// qrScale is the width in pixels of a module of the QR codes served
+const qrScale = 8
+
+var previewTpl = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Short}}</title>
+  <style>
+    body { font-family: sans-serif; max-width: 40em; margin: 3em auto; padding: 0 1em; }
+    th { text-align: left; padding-right: 1em; vertical-align: top; }
+    td { word-break: break-all; }
+  </style>
+</head>
+<body>
+  <h1>{{.Short}}</h1>
+  <p>This short link redirects to:</p>
+  <p><a href="{{.Destination}}">{{.Destination}}</a></p>
+  <table>
+    {{if not .Link.CreatedAt.IsZero}}<tr><th>Created</th><td>{{.Link.CreatedAt.Format "2006-01-02 15:04 MST"}}</td></tr>{{end}}
+    <tr><th>Redirect</th><td>{{.Status}}</td></tr>
+    {{if .Link.NotBefore}}<tr><th>Active from</th><td>{{.Link.NotBefore.Format "2006-01-02 15:04 MST"}}</td></tr>{{end}}
+    {{if .Link.ExpiresAt}}<tr><th>Expires</th><td>{{.Link.ExpiresAt.Format "2006-01-02 15:04 MST"}}</td></tr>{{end}}
+    {{if .Link.MaxHits}}<tr><th>Hit limit</th><td>{{.Link.MaxHits}}</td></tr>{{end}}
+    {{if .Stats}}<tr><th>Hits</th><td>{{.Stats.Hits}}{{if .Stats.Hits}}, last on {{.Stats.LastHit.Format "2006-01-02 15:04 MST"}}{{end}}</td></tr>{{end}}
+    {{if .State}}<tr><th>State</th><td>{{.State}}</td></tr>{{end}}
+  </table>
+  <p><img src="{{.QR}}" alt="QR code of {{.Short}}" width="240"></p>
+</body>
+</html>
+`))
+
+// preview is what the preview page of a link shows
+type preview struct {
+	Link        Link
+	Short       string
+	Destination string
+	Status      string
+	State       string
+	QR          string
+	// Stats is nil when hits aren't recorded
+	Stats *LinkStats
+}
+
+// Preview wraps h, showing a page about the link instead of
+// redirecting when a "+" is appended to its path, like /github+. The
+// page shows where the link leads, when it was created and, if hs
+// isn't nil, how often it was hit. A PNG QR code of every short link
+// is served at /qr/<path>, like /qr/github. All other requests, and
+// requests for paths s doesn't know, are passed on to h.
+func Preview(h http.Handler, s Store, hs HitStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var path string
+		var qr bool
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/qr/"):
+			path, qr = strings.TrimPrefix(r.URL.Path, "/qr"), true
+		case strings.HasSuffix(r.URL.Path, "+"):
+			path = strings.TrimSuffix(r.URL.Path, "+")
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		host := normalizeHost(r.Host)
+		// A link whose path really is /qr/... or ends with + wins
+		own, _, exists, err := lookupLink(ctx, s, host, r.URL.Path)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+			return
+		}
+		l, params, ok, err := lookupLink(ctx, s, host, path)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+			return
+		}
+		if exists && own.Path == r.URL.Path || !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		short := shortURL(r, path)
+		if qr {
+			serveQR(w, short)
+			return
+		}
+
+		p := preview{
+			Link:        l,
+			Short:       short,
+			Destination: l.destination(params, r.URL.RawQuery),
+			Status:      fmt.Sprintf("%d %s", l.status(), http.StatusText(l.status())),
+			QR:          "/qr" + path,
+		}
+		now := time.Now()
+		switch {
+		case !l.active(now):
+			p.State = "Not active yet"
+		case l.expired(now):
+			p.State = "Expired"
+		}
+		if hs != nil {
+			stats, err := hs.LinkStats(ctx, l.Host, l.Path)
+			if err != nil {
+				log.Print(err)
+				http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+				return
+			}
+			p.Stats = &stats
+		}
+
+		var buf bytes.Buffer
+		if err := previewTpl.Execute(&buf, p); err != nil {
+			log.Print(err)
+			http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// serveQR answers with a PNG QR code of text
+func serveQR(w http.ResponseWriter, text string) {
+	img, err := QRCode(text, qrScale)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Print(err)
+		http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// shortURL returns the absolute URL of path on the host the request
+// was made to, honouring the X-Forwarded-Proto header of proxies
+func shortURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + path
+}
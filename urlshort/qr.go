@@ -0,0 +1,525 @@
+package urlshort
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// QR codes are encoded in byte mode with error correction level M,
+// which restores up to 15% of damaged codewords, using the smallest
+// of versions 1 to 13 the data fits in. Version 13 holds 331 bytes,
+// plenty for a short link.
+
+// qrBlocks describes the codewords of a version at level M: the
+// error correction codewords per block, and the number of blocks of
+// the first and second group along with their data codewords. The
+// blocks of the second group hold one more data codeword.
+type qrBlocks struct {
+	ec             int
+	blocks1, data1 int
+	blocks2        int
+}
+
+var qrVersions = []qrBlocks{
+	1:  {10, 1, 16, 0},
+	2:  {16, 1, 28, 0},
+	3:  {26, 1, 44, 0},
+	4:  {18, 2, 32, 0},
+	5:  {24, 2, 43, 0},
+	6:  {16, 4, 27, 0},
+	7:  {18, 4, 31, 0},
+	8:  {22, 2, 38, 2},
+	9:  {22, 3, 36, 2},
+	10: {26, 4, 43, 1},
+	11: {30, 1, 50, 4},
+	12: {22, 6, 36, 2},
+	13: {22, 8, 37, 1},
+}
+
+// qrAlignment are the row and column coordinates of the centers of
+// the alignment patterns per version
+var qrAlignment = [][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+	11: {6, 30, 54},
+	12: {6, 32, 58},
+	13: {6, 34, 62},
+}
+
+func (b qrBlocks) dataCodewords() int {
+	return b.blocks1*b.data1 + b.blocks2*(b.data1+1)
+}
+
+// errQRTooLong is returned for data which doesn't fit in a QR code of
+// the largest version supported
+var errQRTooLong = errors.New("qr: data too long")
+
+// qrCode is the matrix of modules of a QR code, true being dark
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// QRCode encodes data as a QR code, returning an image of it with
+// every module scale pixels wide and the quiet zone around it
+func QRCode(data string, scale int) (image.Image, error) {
+	q, err := encodeQR([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return q.image(scale), nil
+}
+
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		if qrDataBits(v, len(data)) <= qrVersions[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errQRTooLong
+	}
+
+	codewords := qrInterleave(version, qrDataCodewords(version, data))
+
+	q := newQRCode(version)
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q, nil
+}
+
+// qrDataBits returns the number of bits n bytes take in byte mode
+func qrDataBits(version, n int) int {
+	return 4 + qrCountBits(version) + 8*n
+}
+
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataCodewords encodes data in byte mode, padded to the number of
+// data codewords of the version
+func qrDataCodewords(version int, data []byte) []byte {
+	capacity := qrVersions[version].dataCodewords() * 8
+	var bb qrBits
+	bb.append(0x4, 4)
+	bb.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	// Terminator, then zeros up to a byte boundary
+	term := capacity - bb.n
+	if term > 4 {
+		term = 4
+	}
+	bb.append(0, term)
+	bb.append(0, (8-bb.n%8)%8)
+
+	for pad := 0xEC; bb.n < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes
+}
+
+// qrBits is a bit buffer written most significant bit first
+type qrBits struct {
+	bytes []byte
+	n     int
+}
+
+func (b *qrBits) append(v, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if (v>>uint(i))&1 == 1 {
+			b.bytes[b.n/8] |= 0x80 >> uint(b.n%8)
+		}
+		b.n++
+	}
+}
+
+// qrInterleave splits the data codewords into blocks, adds the error
+// correction codewords of each and interleaves them
+func qrInterleave(version int, data []byte) []byte {
+	vb := qrVersions[version]
+	divisor := rsDivisor(vb.ec)
+
+	var blocks, ecs [][]byte
+	for i := 0; i < vb.blocks1+vb.blocks2; i++ {
+		n := vb.data1
+		if i >= vb.blocks1 {
+			n++
+		}
+		block := data[:n]
+		data = data[n:]
+		blocks = append(blocks, block)
+		ecs = append(ecs, rsRemainder(block, divisor))
+	}
+
+	var out []byte
+	for i := 0; i <= vb.data1; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < vb.ec; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the generator polynomial of the given degree,
+// without its leading term, highest coefficients first
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+func newQRCode(version int) *qrCode {
+	size := 17 + 4*version
+	q := &qrCode{size: size}
+	q.modules = make([][]bool, size)
+	q.function = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+// set sets the function module at column x and row y
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *qrCode) drawFunctionPatterns(version int) {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	pos := qrAlignment[version]
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			q.drawAlignment(pos[i], pos[j])
+		}
+	}
+
+	// Reserve the format areas until the mask is known
+	q.drawFormat(0)
+	q.drawVersion(version)
+}
+
+// drawFinder draws a finder pattern along with its separator
+func (q *qrCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= q.size || y < 0 || y >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *qrCode) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M
+// and the mask, along with the dark module
+func (q *qrCode) drawFormat(mask int) {
+	data := 0<<3 | mask // level M is 0b00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawVersion draws both copies of the version information, which
+// versions 7 and up carry
+func (q *qrCode) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order, two columns
+// at a time from the bottom right, skipping the function modules
+func (q *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			y := vert
+			if upward {
+				y = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				q.modules[y][x] = (codewords[i/8]>>uint(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by the mask, so applying
+// it twice undoes it
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.function[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			q.modules[y][x] = q.modules[y][x] != flip
+		}
+	}
+}
+
+var qrFinderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores how hard the code is to read, lower being better
+func (q *qrCode) penalty() int {
+	p := 0
+	at := func(x, y int, transposed bool) bool {
+		if transposed {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+
+	for _, transposed := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			// Runs of five or more modules of the same color
+			run := 1
+			for x := 1; x < q.size; x++ {
+				if at(x, y, transposed) == at(x-1, y, transposed) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+
+			// Patterns looking like finders
+			for x := 0; x+len(qrFinderLike[0]) <= q.size; x++ {
+				for _, pattern := range qrFinderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(x+k, y, transposed) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size &&
+				c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+
+	total := q.size * q.size
+	p += abs(dark*100/total-50) / 5 * 10
+	return p
+}
+
+// image renders the code with every module scale pixels wide and a
+// quiet zone of four modules around it
+func (q *qrCode) image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	const quiet = 4
+	n := (q.size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if !q.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quiet)*scale+dx, (y+quiet)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package urlshort
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQRErrorCorrection(t *testing.T) {
+	// The data codewords of HELLO WORLD in alphanumeric mode at 1-M
+	// and their error correction codewords, from the worked example
+	// at thonky.com
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	// HELLO WORLD in byte mode at 1-M with mask 4
+	want := []string{
+		"#######.##..#.#######",
+		"#.....#....#..#.....#",
+		"#.###.#..#.#..#.###.#",
+		"#.###.#.#..#..#.###.#",
+		"#.###.#.###.#.#.###.#",
+		"#.....#.#..#..#.....#",
+		"#######.#.#.#.#######",
+		"........#..##........",
+		"#...#.######.#####..#",
+		"...#....#.###....####",
+		"..######..##.##.#..#.",
+		"#####...##...#.......",
+		"#####.#.#.#.#.##..##.",
+		"........#.#.####.#.##",
+		"#######.###.#.#.##.#.",
+		"#.....#..#.###.##..##",
+		"#.###.#.##.#.##...##.",
+		"#.###.#..#..#...##.##",
+		"#.###.#..###...###...",
+		"#.....#....#.#.......",
+		"#######.#########.#.#",
+	}
+
+	q, err := encodeQR([]byte("HELLO WORLD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, q.size)
+	for y := range got {
+		got[y] = qrBitString(q.modules[y], ".#")
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestQRVersion(t *testing.T) {
+	q, err := encodeQR(bytes.Repeat([]byte("a"), 110))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.size != 45 {
+		t.Fatalf("got size %d, want 45 for version 7", q.size)
+	}
+
+	// The version information of version 7, 000111110010010100 in
+	// ISO/IEC 18004 Annex D, least significant bit first
+	const want = "001010010011111000"
+	var bottomLeft, topRight []bool
+	for i := 0; i < 18; i++ {
+		bottomLeft = append(bottomLeft, q.modules[q.size-11+i%3][i/3])
+		topRight = append(topRight, q.modules[i/3][q.size-11+i%3])
+	}
+	if got := qrBitString(bottomLeft, "01"); got != want {
+		t.Errorf("got %s above the bottom left finder, want %s", got, want)
+	}
+	if got := qrBitString(topRight, "01"); got != want {
+		t.Errorf("got %s left of the top right finder, want %s", got, want)
+	}
+}
+
+func TestQRTooLong(t *testing.T) {
+	if _, err := QRCode(strings.Repeat("a", 332), 1); err != errQRTooLong {
+		t.Errorf("got %v, want %v", err, errQRTooLong)
+	}
+}
+
+// qrBitString returns modules as a string of the first character of
+// chars for light modules and the second for dark ones
+func qrBitString(modules []bool, chars string) string {
+	b := make([]byte, len(modules))
+	for i, dark := range modules {
+		b[i] = chars[0]
+		if dark {
+			b[i] = chars[1]
+		}
+	}
+	return string(b)
+}
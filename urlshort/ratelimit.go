@@ -0,0 +1,150 @@
+package urlshort
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets of clients which haven't
+// made requests long enough to be full again are forgotten
+const sweepInterval = time.Minute
+
+// RateLimiter limits the requests of every client IP with a token
+// bucket: a client may make burst requests at once, then rate requests
+// per second. Use Limit to wrap any of the handlers of this package,
+// or the admin API, with it.
+type RateLimiter struct {
+	// TrustProxy takes the client IP from the last address of the
+	// X-Forwarded-For header when set, for servers behind a proxy.
+	// Set it before using the limiter.
+	TrustProxy bool
+
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	allowed   uint64
+	limited   uint64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitStats are the counters of a RateLimiter
+type RateLimitStats struct {
+	// Allowed and Limited count the requests let through and refused
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+	// Clients is the number of clients currently tracked
+	Clients int `json:"clients"`
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate requests per
+// second to every client, with bursts of up to burst requests. A rate
+// of zero or less disables the limit.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: rate, burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// Limit wraps h, answering requests of clients over the limit with
+// 429 Too Many Requests and a Retry-After header instead of passing
+// them on to h
+func (l *RateLimiter) Limit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retry := l.Allow(l.clientIP(r))
+		if !ok {
+			secs := int(math.Ceil(retry.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			http.Error(w, "Too many requests, slow down.", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Allow takes a token from the bucket of the client with key,
+// reporting false along with how long to wait for the next token if
+// there is none
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return l.allow(key, time.Now())
+}
+
+func (l *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		l.allowed++
+		return true, 0
+	}
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		l.allowed++
+		return true, 0
+	}
+	l.limited++
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep forgets the buckets which are full again, as if their clients
+// were never seen
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Stats returns the counters of the limiter
+func (l *RateLimiter) Stats() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return RateLimitStats{Allowed: l.allowed, Limited: l.limited, Clients: len(l.buckets)}
+}
+
+// clientIP returns the IP address of the client making the request
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.TrustProxy {
+		fwd := r.Header.Get("X-Forwarded-For")
+		if i := strings.LastIndex(fwd, ","); i >= 0 {
+			fwd = fwd[i+1:]
+		}
+		if ip := strings.TrimSpace(fwd); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
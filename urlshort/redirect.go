@@ -0,0 +1,164 @@
+package urlshort
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// linkGetter is implemented by stores which can return the whole link
+// for a path, so that its status, activation window and hit limit are
+// honoured
+type linkGetter interface {
+	Get(ctx context.Context, path string) (Link, error)
+}
+
+// linkMatcher is implemented by stores supporting prefixes, patterns
+// and hosts, returning the link matching path on host along with its
+// params
+type linkMatcher interface {
+	match(ctx context.Context, host, path string) (Link, map[string]string, bool, error)
+}
+
+// hitLimiter is implemented by stores counting the hits of links with
+// a limit themselves. countHit counts a hit of the link with key,
+// reporting false once it was hit max times.
+type hitLimiter interface {
+	countHit(ctx context.Context, key string, max int) (bool, error)
+}
+
+// status returns the status code to redirect with
+func (l Link) status() int {
+	if l.Status == 0 {
+		return http.StatusFound
+	}
+	return l.Status
+}
+
+// active tells whether the link is past its not_before time
+func (l Link) active(now time.Time) bool {
+	return l.NotBefore == nil || !now.Before(*l.NotBefore)
+}
+
+// expired tells whether the link is past its expires_at time
+func (l Link) expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// redirector redirects requests according to the link found for them,
+// counting the hits of links with a limit. Hits are counted by the
+// store when it is a hitLimiter, in memory from the time the handler
+// was created otherwise.
+type redirector struct {
+	fallback http.Handler
+	limiter  hitLimiter
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+func newRedirector(fallback http.Handler) *redirector {
+	return &redirector{fallback: fallback, hits: make(map[string]int)}
+}
+
+// limiterOf returns the hitLimiter of s, looking through the stores
+// wrapped by this package, or nil if it has none
+func limiterOf(s Store) hitLimiter {
+	for {
+		switch t := s.(type) {
+		case hitLimiter:
+			return t
+		case *timedStore:
+			s = t.Store
+		default:
+			return nil
+		}
+	}
+}
+
+// linkHandler is like MapHandler, for links with all their fields
+func linkHandler(links []Link, fallback http.Handler) http.HandlerFunc {
+	rt := newRouter(links)
+	rd := newRedirector(fallback)
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, params, ok := rt.match(r.Host, r.URL.Path)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		rd.serve(w, r, l, params)
+	}
+}
+
+// serve redirects the request for l, which matched the path of the
+// request with params
+func (rd *redirector) serve(w http.ResponseWriter, r *http.Request, l Link, params map[string]string) {
+	now := time.Now()
+	if !l.active(now) {
+		rd.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	gone := l.expired(now)
+	if !gone && l.MaxHits > 0 {
+		ok, err := rd.hit(r.Context(), l.key(), l.MaxHits)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+			return
+		}
+		gone = !ok
+	}
+
+	markServed(r, l)
+	if gone {
+		http.Error(w, "This link has expired.", http.StatusGone)
+		return
+	}
+	http.Redirect(w, r, l.destination(params, r.URL.RawQuery), l.status())
+}
+
+// hit counts a hit of the link with key, reporting false once it was
+// hit max times
+func (rd *redirector) hit(ctx context.Context, key string, max int) (bool, error) {
+	if rd.limiter != nil {
+		return rd.limiter.countHit(ctx, key, max)
+	}
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if rd.hits[key] >= max {
+		return false, nil
+	}
+	rd.hits[key]++
+	return true, nil
+}
+
+// servedLink is filled in with the link a request was answered for by
+// the handlers of this package, so that the middleware around them can
+// tell which link was hit, if any
+type servedLink struct {
+	link Link
+	ok   bool
+}
+
+type servedKey struct{}
+
+// withServed returns r with a servedLink for the handlers to fill in,
+// reusing the one of an outer middleware if there is one
+func withServed(r *http.Request) (*http.Request, *servedLink) {
+	if sl, ok := r.Context().Value(servedKey{}).(*servedLink); ok {
+		return r, sl
+	}
+	sl := &servedLink{}
+	return r.WithContext(context.WithValue(r.Context(), servedKey{}, sl)), sl
+}
+
+// markServed records that r was answered for l
+func markServed(r *http.Request, l Link) {
+	if sl, ok := r.Context().Value(servedKey{}).(*servedLink); ok {
+		sl.link, sl.ok = l, true
+	}
+}
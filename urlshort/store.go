@@ -0,0 +1,348 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store looks up the URL a path redirects to. ok is false when the
+// path isn't known to the store. Paths are prefixed by a host like
+// "go.team-a/docs" to look up links scoped to it, see host.go.
+type Store interface {
+	Lookup(ctx context.Context, path string) (url string, ok bool, err error)
+}
+
+// StoreHandler will return an http.HandlerFunc that looks up every
+// requested path in the store and redirects to the URL found. As the
+// store is consulted on every request, redirects can be changed
+// without restarting the server. If the path is not in the store,
+// the fallback http.Handler will be called instead.
+//
+// Links scoped to the host of the request are looked up first, then
+// the links in the default scope. Stores which can return whole links,
+// like all the stores of this package, have the status, activation
+// window and hit limit of their links honoured as with YAMLHandler.
+func StoreHandler(s Store, fallback http.Handler) http.HandlerFunc {
+	rd := newRedirector(fallback)
+	rd.limiter = limiterOf(s)
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, params, ok, err := lookupLink(r.Context(), s, normalizeHost(r.Host), r.URL.Path)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Something went wrong...", http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			rd.serve(w, r, l, params)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	}
+}
+
+// lookupLink returns the whole link for path on host, matching
+// prefixes and patterns, if the store can return it, or a link with
+// just the URL otherwise
+func lookupLink(ctx context.Context, s Store, host, path string) (Link, map[string]string, bool, error) {
+	if lm, ok := s.(linkMatcher); ok {
+		return lm.match(ctx, host, path)
+	}
+
+	for _, h := range scopes(host) {
+		if lg, ok := s.(linkGetter); ok {
+			l, err := lg.Get(ctx, h+path)
+			if err == ErrNotFound {
+				continue
+			}
+			return l, nil, err == nil, err
+		}
+
+		dest, ok, err := s.Lookup(ctx, h+path)
+		if ok || err != nil {
+			return Link{Host: h, Path: path, URL: dest}, nil, ok, err
+		}
+	}
+	return Link{}, nil, false, nil
+}
+
+// MultiStore is a Store consulting several stores in order, the first
+// store knowing a path winning
+type MultiStore []Store
+
+// Lookup returns the URL for path from the first store knowing it
+func (m MultiStore) Lookup(ctx context.Context, path string) (string, bool, error) {
+	for _, s := range m {
+		url, ok, err := s.Lookup(ctx, path)
+		if ok || err != nil {
+			return url, ok, err
+		}
+	}
+	return "", false, nil
+}
+
+func (m MultiStore) match(ctx context.Context, host, path string) (Link, map[string]string, bool, error) {
+	for _, s := range m {
+		l, params, ok, err := lookupLink(ctx, s, host, path)
+		if ok || err != nil {
+			return l, params, ok, err
+		}
+	}
+	return Link{}, nil, false, nil
+}
+
+// Errors returned by a LinkStore
+var (
+	ErrNotFound = errors.New("link not found")
+	ErrExists   = errors.New("path already in use")
+)
+
+// LinkStore is a Store whose links can be managed at runtime. Links
+// are identified by their path, prefixed by their host if they have
+// one, like "go.team-a/docs".
+type LinkStore interface {
+	Store
+	// Get returns the link for path, ErrNotFound if there is none
+	Get(ctx context.Context, path string) (Link, error)
+	// List returns all links ordered by host and path
+	List(ctx context.Context) ([]Link, error)
+	// Create adds a new link, ErrExists if its path is taken
+	Create(ctx context.Context, l Link) error
+	// Update replaces an existing link, ErrNotFound if there is none
+	Update(ctx context.Context, l Link) error
+	// Delete removes the link for path, ErrNotFound if there is none
+	Delete(ctx context.Context, path string) error
+}
+
+// MemoryStore is a LinkStore keeping its links in memory. It is safe
+// for concurrent use.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	links map[string]Link
+	// router is rebuilt from links on every change
+	router *router
+}
+
+// NewMemoryStore creates a MemoryStore holding the given mapping of
+// paths to urls, taking keys of the same form as MapHandler
+func NewMemoryStore(urlMap map[string]string) *MemoryStore {
+	s := &MemoryStore{links: make(map[string]Link)}
+	for key, url := range urlMap {
+		host, path := splitKey(key)
+		l := Link{Host: host, Path: path, URL: url}
+		s.links[l.key()] = l
+	}
+	s.rebuild()
+	return s
+}
+
+// rebuild must be called with the lock held for writing
+func (s *MemoryStore) rebuild() {
+	links := make([]Link, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	s.router = newRouter(links)
+}
+
+// Lookup returns the URL path redirects to, which can match a prefix
+// or pattern as well
+func (s *MemoryStore) Lookup(ctx context.Context, path string) (string, bool, error) {
+	host, path := splitKey(path)
+	l, params, ok, err := s.match(ctx, host, path)
+	if !ok {
+		return "", false, err
+	}
+	return l.destination(params, ""), true, nil
+}
+
+func (s *MemoryStore) match(ctx context.Context, host, path string) (Link, map[string]string, bool, error) {
+	s.mu.RLock()
+	rt := s.router
+	s.mu.RUnlock()
+	if rt == nil {
+		return Link{}, nil, false, nil
+	}
+	l, params, ok := rt.match(host, path)
+	return l, params, ok, nil
+}
+
+// Get returns the link for path
+func (s *MemoryStore) Get(ctx context.Context, path string) (Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.links[normalizeKey(path)]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+	return l, nil
+}
+
+// List returns all links ordered by path
+func (s *MemoryStore) List(ctx context.Context) ([]Link, error) {
+	s.mu.RLock()
+	links := make([]Link, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(links, func(i, j int) bool { return links[i].key() < links[j].key() })
+	return links, nil
+}
+
+// Create adds a new link
+func (s *MemoryStore) Create(ctx context.Context, l Link) error {
+	l.Host = normalizeHost(l.Host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[l.key()]; ok {
+		return ErrExists
+	}
+	s.links[l.key()] = l
+	s.rebuild()
+	return nil
+}
+
+// Update replaces an existing link
+func (s *MemoryStore) Update(ctx context.Context, l Link) error {
+	l.Host = normalizeHost(l.Host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[l.key()]; !ok {
+		return ErrNotFound
+	}
+	s.links[l.key()] = l
+	s.rebuild()
+	return nil
+}
+
+// Delete removes the link for path
+func (s *MemoryStore) Delete(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := normalizeKey(path)
+	if _, ok := s.links[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.links, key)
+	s.rebuild()
+	return nil
+}
+
+// Replace swaps all links of the store for the given ones at once.
+// Later links take precedence over earlier ones with the same path.
+func (s *MemoryStore) Replace(links []Link) {
+	m := make(map[string]Link, len(links))
+	for _, l := range links {
+		m[l.key()] = l
+	}
+	rt := newRouter(links)
+	s.mu.Lock()
+	s.links, s.router = m, rt
+	s.mu.Unlock()
+}
+
+// FileStore is a Store backed by a YAML, JSON or CSV file. YAML and
+// JSON files are in the format accepted by YAMLHandler and
+// JSONHandler, see parseCSV for CSV files. Files ending in .json are
+// read as JSON, files ending in .csv as CSV and anything else as YAML.
+// The file is read when the store is created and again on every call
+// to Reload, see Watch to reload it whenever it changes.
+type FileStore struct {
+	links MemoryStore
+	path  string
+	opts  *options
+
+	// modTime and size of the file when it was last read, used to
+	// tell whether it changed since, and the metrics counting the
+	// reloads, see Metrics.Store
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	metrics *Metrics
+}
+
+// NewFileStore creates a FileStore reading its redirects from path.
+// The links of the file are validated like by YAMLHandler.
+func NewFileStore(path string, opts ...Option) (*FileStore, error) {
+	s := &FileStore{path: path, opts: newOptions(opts)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the file again, replacing the redirects of the store.
+// The previous redirects are kept if the file can't be read.
+func (s *FileStore) Reload() error {
+	err := s.load()
+	s.mu.Lock()
+	m := s.metrics
+	s.mu.Unlock()
+	if m != nil {
+		m.reloaded(s.path, err)
+	}
+	return err
+}
+
+func (s *FileStore) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.modTime, s.size = info.ModTime(), info.Size()
+	s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	links, err := parseFile(s.path, data)
+	if err == nil {
+		err = validate(links, s.opts)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+
+	s.links.Replace(links)
+	return nil
+}
+
+// Lookup returns the URL path redirects to
+func (s *FileStore) Lookup(ctx context.Context, path string) (string, bool, error) {
+	return s.links.Lookup(ctx, path)
+}
+
+func (s *FileStore) match(ctx context.Context, host, path string) (Link, map[string]string, bool, error) {
+	return s.links.match(ctx, host, path)
+}
+
+// Get returns the link for path
+func (s *FileStore) Get(ctx context.Context, path string) (Link, error) {
+	return s.links.Get(ctx, path)
+}
+
+// parseFile parses data as JSON, CSV or YAML, depending on the
+// extension of the file it was read from
+func parseFile(path string, data []byte) ([]Link, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSON(data)
+	case ".csv":
+		return parseCSV(data)
+	}
+	return parseYaml(data)
+}
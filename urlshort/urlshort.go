@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -14,16 +15,16 @@ import (
 // that each key in the map points to, in string format).
 // If the path is not provided in the map, then the fallback
 // http.Handler will be called instead.
+//
+// Keys can be prefixes and patterns as well, see Link, and can start
+// with a host like "go.team-a/docs" to only redirect requests for it.
 func MapHandler(urlMap map[string]string, fallback http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		dest, ok := urlMap[path]
-		if ok {
-			http.Redirect(w, r, dest, http.StatusFound)
-			return
-		}
-		fallback.ServeHTTP(w, r)
+	links := make([]Link, 0, len(urlMap))
+	for key, url := range urlMap {
+		host, path := splitKey(key)
+		links = append(links, Link{Host: host, Path: path, URL: url})
 	}
+	return linkHandler(links, fallback)
 }
 
 // YAMLHandler will parse the provided YAML and then return
@@ -34,67 +35,105 @@ func MapHandler(urlMap map[string]string, fallback http.Handler) http.HandlerFun
 //
 // YAML is expected to be in the format:
 //
-//     - path: /some-path
-//       url: https://www.some-url.com/demo
+//   - path: /some-path
+//     url: https://www.some-url.com/demo
+//
+// Entries can set the optional fields of Link as well, like:
+//
+//   - path: /launch
+//     url: https://www.some-url.com/launch
+//     status: 301
+//     not_before: 2020-06-01T00:00:00Z
+//     expires_at: 2020-07-01T00:00:00Z
+//     max_hits: 1000
+//
+// Expired links and links hit max_hits times answer 410 Gone, links
+// before their not_before time are left to the fallback.
 //
 // The only errors that can be returned all related to having
-// invalid YAML data.
+// invalid YAML data, or ValidationErrors for entries which aren't
+// valid links, see the Options for what is checked.
 //
 // See MapHandler to create a similar http.HandlerFunc via
 // a mapping of paths to urls.
-func YAMLHandler(yamlBytes []byte, fallback http.Handler) (http.HandlerFunc, error) {
+func YAMLHandler(yamlBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
 	parsedYaml, err := parseYaml(yamlBytes)
 	if err != nil {
 		return nil, err
 	}
-
-	paths := buildMap(parsedYaml)
-	return MapHandler(paths, fallback), err
-}
-
-func buildMap(pathURLs []pathURL) map[string]string {
-	paths := make(map[string]string)
-	for _, pu := range pathURLs {
-		paths[pu.Path] = pu.URL
+	if err := validate(parsedYaml, newOptions(opts)); err != nil {
+		return nil, err
 	}
 
-	return paths
+	return linkHandler(parsedYaml, fallback), err
 }
 
-func parseYaml(yamlBytes []byte) ([]pathURL, error) {
-	var pathURLs []pathURL
-	err := yaml.Unmarshal(yamlBytes, &pathURLs)
+func parseYaml(yamlBytes []byte) ([]Link, error) {
+	var links []Link
+	err := yaml.Unmarshal(yamlBytes, &links)
 	if err != nil {
 		return nil, err
 	}
-	return pathURLs, nil
+	return links, nil
 }
 
-type pathURL struct {
+// Link is a short path along with the URL it redirects to. Only Path
+// and URL are required, the other fields restrict how and when the
+// link redirects.
+//
+// The path can be a prefix like "/gh/*" or a pattern like
+// "/u/{user}", with the URL using the parts of the path matched, as
+// in "https://github.com/{user}". See the comment in pattern.go for
+// the details and precedence.
+type Link struct {
 	Path string `yaml:"path" json:"path"`
 	URL  string `yaml:"url" json:"url"`
+	// Host scopes the link to requests for that host, see host.go
+	Host string `yaml:"host,omitempty" json:"host,omitempty"`
+	// ForwardQuery adds the query string of the request to the URL
+	ForwardQuery bool `yaml:"forward_query,omitempty" json:"forward_query,omitempty"`
+	// Status is the status code of the redirect, one of 301, 302,
+	// 307 and 308. Defaults to 302.
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	// NotBefore is the time the link starts redirecting, until then
+	// it is treated as unknown
+	NotBefore *time.Time `yaml:"not_before,omitempty" json:"not_before,omitempty"`
+	// ExpiresAt is the time from which the link is gone
+	ExpiresAt *time.Time `yaml:"expires_at,omitempty" json:"expires_at,omitempty"`
+	// MaxHits is the number of times the link redirects before it is
+	// gone, zero means no limit. BoltStore keeps the count along with
+	// its links, so it survives restarts and is shared by all handlers
+	// using the store. The hits of other links are counted in memory,
+	// by each handler from the time it was created.
+	MaxHits int `yaml:"max_hits,omitempty" json:"max_hits,omitempty"`
+	// CreatedAt is set for links created through a LinkStore
+	CreatedAt time.Time `yaml:"created_at,omitempty" json:"created_at,omitempty"`
 }
 
 // JSONHandler will parse the provided JSON and tries
-// to map any Path provides with it's URL
-func JSONHandler(jsonBytes []byte, fallback http.Handler) (http.HandlerFunc, error) {
+// to map any Path provides with it's URL. Entries can
+// set the same fields as for YAMLHandler, and are
+// validated the same way.
+func JSONHandler(jsonBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
 	parsedJSON, err := parseJSON(jsonBytes)
 	if err != nil {
 		return nil, err
 	}
+	if err := validate(parsedJSON, newOptions(opts)); err != nil {
+		return nil, err
+	}
 
-	paths := buildMap(parsedJSON)
-	return MapHandler(paths, fallback), err
+	return linkHandler(parsedJSON, fallback), err
 }
 
-func parseJSON(jsonBytes []byte) ([]pathURL, error) {
-	var pathURLs []pathURL
-	err := json.Unmarshal(jsonBytes, &pathURLs)
+func parseJSON(jsonBytes []byte) ([]Link, error) {
+	var links []Link
+	err := json.Unmarshal(jsonBytes, &links)
 	if err != nil {
 		return nil, err
 	}
 
-	return pathURLs, nil
+	return links, nil
 }
 
 // Hello says hello to world
@@ -0,0 +1,216 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ValidationError describes why an entry of a list of links is invalid
+type ValidationError struct {
+	// Index is the position of the entry in the list, starting at 1
+	Index  int
+	Path   string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("entry %d (%s): %s", e.Index, e.Path, e.Reason)
+}
+
+// ValidationErrors are all the problems found in a list of links
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validate checks every link, returning ValidationErrors listing all
+// problems found, nil if there are none. Links must have a path
+// starting with a slash and an absolute http or https URL to an
+// allowed host, use a supported status and only use the parameters
+// their path defines. No two links may have the same path and host,
+// and no links may redirect to each other in a loop.
+func validate(links []Link, o *options) error {
+	var errs ValidationErrors
+	invalid := func(i int, l Link, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Index: i + 1, Path: l.Path, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]int)
+	for i, l := range links {
+		if reason := o.checkLink(l); reason != "" {
+			invalid(i, l, "%s", reason)
+		}
+
+		if first, ok := seen[l.key()]; ok {
+			invalid(i, l, "duplicate of entry %d", first+1)
+		} else {
+			seen[l.key()] = i
+		}
+	}
+
+	for _, loop := range o.loops(links) {
+		invalid(loop[0], links[loop[0]], "redirects in a loop: %s", loopPath(links, loop))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkLink returns why the link is invalid on its own, if it is
+func (o *options) checkLink(l Link) string {
+	switch {
+	case l.Path == "":
+		return "missing path"
+	case !strings.HasPrefix(l.Path, "/"):
+		return "path must start with /"
+	case strings.TrimSpace(l.URL) == "":
+		return "missing url"
+	}
+
+	switch l.Status {
+	case 0, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Sprintf("unsupported status %d, use 301, 302, 307 or 308", l.Status)
+	}
+	if err := checkPattern(l); err != nil {
+		return err.Error()
+	}
+
+	u, err := url.Parse(fillParams(l.URL))
+	if err != nil {
+		return fmt.Sprintf("invalid url: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("url %q must be an absolute http or https url", l.URL)
+	}
+
+	// A host with parameters could turn into any host once filled in,
+	// so it can't be checked against the allowed or denied hosts
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case hostIn(host, o.denied):
+		return fmt.Sprintf("host %s is denied", host)
+	case len(o.allowed) == 0 && len(o.denied) == 0:
+	case strings.Contains(hostPart(l.URL), "{"):
+		return "url host can't use parameters when destination hosts are restricted"
+	case len(o.allowed) == 0:
+	case !hostIn(host, o.allowed):
+		return fmt.Sprintf("host %s is not allowed", host)
+	}
+	return ""
+}
+
+// loops returns the indexes of the links redirecting in a loop, one
+// list of links per loop starting at its first link
+func (o *options) loops(links []Link) [][]int {
+	short := make(map[string]bool)
+	for _, h := range o.shortHosts {
+		short[normalizeHost(h)] = true
+	}
+	index := make(map[string]int)
+	for i, l := range links {
+		index[l.key()] = i
+		if l.Host != "" {
+			short[normalizeHost(l.Host)] = true
+		}
+	}
+	rt := newRouter(links)
+
+	// next returns the link the link at i redirects to, -1 if none
+	next := func(i int) int {
+		dest := links[i].URL
+		if strings.Contains(dest, "{") {
+			return -1
+		}
+		u, err := url.Parse(dest)
+		if err != nil || !short[normalizeHost(u.Host)] {
+			return -1
+		}
+		l, _, ok := rt.match(u.Host, u.Path)
+		if !ok {
+			return -1
+		}
+		return index[l.key()]
+	}
+
+	var loops [][]int
+	inLoop := make(map[int]bool)
+	for start := range links {
+		if inLoop[start] {
+			continue
+		}
+		seen := make(map[int]bool)
+		loop := []int{start}
+		for i := next(start); i >= 0 && !seen[i]; i = next(i) {
+			if i == start {
+				for _, j := range loop {
+					inLoop[j] = true
+				}
+				loops = append(loops, loop)
+				break
+			}
+			seen[i] = true
+			loop = append(loop, i)
+		}
+	}
+	return loops
+}
+
+func loopPath(links []Link, loop []int) string {
+	var hops []string
+	for _, i := range append(loop, loop[0]) {
+		hops = append(hops, links[i].Host+links[i].Path)
+	}
+	return strings.Join(hops, " -> ")
+}
+
+// hostIn tells whether host is one of hosts, where "*.example.com"
+// matches any subdomain of example.com
+func hostIn(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = normalizeHost(strings.TrimSpace(h))
+		if h == host || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillParams replaces the parameters of a URL like {rest} with a
+// placeholder, for the URL to be parsed
+func fillParams(u string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(u, "{")
+		j := strings.Index(u, "}")
+		if i < 0 || j < i {
+			b.WriteString(u)
+			return b.String()
+		}
+		b.WriteString(u[:i])
+		b.WriteString("x")
+		u = u[j+1:]
+	}
+}
+
+// hostPart returns the part of a URL up to its path
+func hostPart(u string) string {
+	rest := u
+	if i := strings.Index(rest, "//"); i >= 0 {
+		rest = rest[i+2:]
+	}
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
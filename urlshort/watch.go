@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is how often a watched file is checked for
+// changes when no interval is given
+const DefaultWatchInterval = 2 * time.Second
+
+// FileHandler will return an http.HandlerFunc redirecting the paths
+// of the YAML, JSON or CSV file at path, see FileStore. The file is
+// checked for changes every interval until ctx is done, and read
+// again when it changed. If the changed file can't be parsed, the
+// error is logged and the previous redirects are kept. The only errors
+// that can be returned are those of reading the file the first time.
+func FileHandler(ctx context.Context, path string, interval time.Duration, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	s, err := NewFileStore(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.Watch(ctx, interval)
+	return StoreHandler(s, fallback), nil
+}
+
+// Watch polls the file every interval until ctx is done, reloading
+// it whenever its modification time or size changed. Errors are
+// logged, keeping the previous redirects until the file is fixed.
+func (s *FileStore) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if !s.changed() {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("keeping the previous redirects: %v", err)
+			continue
+		}
+		log.Printf("reloaded %s", s.path)
+	}
+}
+
+// changed tells whether the file changed since it was last read. A
+// file which can't be stat'ed is considered changed, for Reload to
+// report the error.
+func (s *FileStore) changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}